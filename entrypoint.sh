@@ -6,6 +6,11 @@ DEFAULT_HOST_WORK_DIR="${HOST_PWD}/docker/appstore"
 DEFAULT_ENV_FILE="/var/www/.env"
 DEFAULT_WEB_DIR="/usr/share/appstore/web"
 DEFAULT_RUN_MODE="release"
+DEFAULT_UPDATE_INTERVAL="0"
+DEFAULT_UPDATE_WINDOW=""
+DEFAULT_APP_DOMAIN=""
+DEFAULT_APP_TLS_CERT=""
+DEFAULT_APP_TLS_KEY=""
 
 # 使用环境变量（如果存在），否则使用默认值
 WORK_DIR=${WORK_DIR:-$DEFAULT_WORK_DIR}
@@ -13,6 +18,11 @@ HOST_WORK_DIR=${HOST_WORK_DIR:-$DEFAULT_HOST_WORK_DIR}
 ENV_FILE=${ENV_FILE:-$DEFAULT_ENV_FILE}
 WEB_DIR=${WEB_DIR:-$DEFAULT_WEB_DIR}
 RUN_MODE=${RUN_MODE:-$DEFAULT_RUN_MODE}
+UPDATE_INTERVAL=${UPDATE_INTERVAL:-$DEFAULT_UPDATE_INTERVAL}
+UPDATE_WINDOW=${UPDATE_WINDOW:-$DEFAULT_UPDATE_WINDOW}
+APP_DOMAIN=${APP_DOMAIN:-$DEFAULT_APP_DOMAIN}
+APP_TLS_CERT=${APP_TLS_CERT:-$DEFAULT_APP_TLS_CERT}
+APP_TLS_KEY=${APP_TLS_KEY:-$DEFAULT_APP_TLS_KEY}
 
 # 复制所有应用到工作目录
 if [ "$RUN_MODE" = "strict" ]; then
@@ -28,6 +38,9 @@ echo "HOST_WORK_DIR: $HOST_WORK_DIR"
 echo "ENV_FILE: $ENV_FILE"
 echo "WEB_DIR: $WEB_DIR"
 echo "RUN_MODE: $RUN_MODE"
+echo "UPDATE_INTERVAL: $UPDATE_INTERVAL"
+echo "UPDATE_WINDOW: $UPDATE_WINDOW"
+echo "APP_DOMAIN: $APP_DOMAIN"
 
 # 执行启动命令
-exec /usr/share/appstore/cli --work-dir "$WORK_DIR" --host-work-dir "$HOST_WORK_DIR" --env-file "$ENV_FILE" --web-dir "$WEB_DIR" --mode "$RUN_MODE"
\ No newline at end of file
+exec /usr/share/appstore/cli --work-dir "$WORK_DIR" --host-work-dir "$HOST_WORK_DIR" --env-file "$ENV_FILE" --web-dir "$WEB_DIR" --mode "$RUN_MODE" --update-interval "$UPDATE_INTERVAL" --update-window "$UPDATE_WINDOW" --app-domain "$APP_DOMAIN" --app-tls-cert "$APP_TLS_CERT" --app-tls-key "$APP_TLS_KEY"
\ No newline at end of file
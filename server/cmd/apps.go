@@ -0,0 +1,444 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"appstore/server/global"
+	"appstore/server/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cliFormat   string
+	cliLanguage string
+
+	installVersion         string
+	installParams          []string
+	installCPULimit        string
+	installMemoryLimit     string
+	installRequiresFlag    bool
+	uninstallForce         bool
+	logsLines              int
+	updateSourcesDryRun    bool
+	updateSourcesOverwrite []string
+
+	listCmd = &cobra.Command{
+		Use:    "list",
+		Short:  "列出所有应用",
+		Args:   cobra.NoArgs,
+		PreRun: runCliPre,
+		Run:    runList,
+	}
+	infoCmd = &cobra.Command{
+		Use:    "info <应用ID>",
+		Short:  "查看应用详情",
+		Args:   cobra.ExactArgs(1),
+		PreRun: runCliPre,
+		Run:    runInfo,
+	}
+	installCmd = &cobra.Command{
+		Use:    "install <应用ID>",
+		Short:  "安装或升级应用",
+		Long:   "安装或升级应用，未指定的参数使用已安装时的参数或字段默认值，等待安装完成（服务运行时交给服务执行，否则在当前进程中执行，中断时应用标记为 error）",
+		Args:   cobra.ExactArgs(1),
+		PreRun: runCliPre,
+		Run:    runInstall,
+	}
+	uninstallCmd = &cobra.Command{
+		Use:    "uninstall <应用ID>",
+		Short:  "卸载应用",
+		Long:   "卸载应用，有其他已安装应用依赖此应用时需要 --force 才能卸载（--force 时卸载前钩子失败也继续卸载），等待卸载完成（服务运行时交给服务执行，否则在当前进程中执行，中断时应用标记为 error）",
+		Args:   cobra.ExactArgs(1),
+		PreRun: runCliPre,
+		Run:    runUninstall,
+	}
+	logsCmd = &cobra.Command{
+		Use:    "logs <应用ID>",
+		Short:  "查看应用日志",
+		Args:   cobra.ExactArgs(1),
+		PreRun: runCliPre,
+		Run:    runLogs,
+	}
+	updateSourcesCmd = &cobra.Command{
+		Use:    "update-sources",
+		Short:  "从远程仓库更新应用列表",
+		Args:   cobra.NoArgs,
+		PreRun: runCliPre,
+		Run:    runUpdateSources,
+	}
+	statusCmd = &cobra.Command{
+		Use:    "status [应用ID...]",
+		Short:  "查看应用安装状态（默认所有已安装的应用）",
+		PreRun: runCliPre,
+		Run:    runStatus,
+	}
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{listCmd, infoCmd, installCmd, uninstallCmd, logsCmd, updateSourcesCmd, statusCmd} {
+		cmd.Flags().StringVar(&cliFormat, "format", "table", "输出格式 (table/json)")
+		cmd.Flags().StringVar(&cliLanguage, "lang", "zh", "输出语言")
+		rootCmd.AddCommand(cmd)
+	}
+
+	installCmd.Flags().StringVar(&installVersion, "version", "latest", "安装的版本")
+	installCmd.Flags().StringArrayVar(&installParams, "param", nil, "应用参数，格式 key=value，可重复")
+	installCmd.Flags().StringVar(&installCPULimit, "cpu-limit", "", "CPU限制")
+	installCmd.Flags().StringVar(&installMemoryLimit, "memory-limit", "", "内存限制")
+	installCmd.Flags().BoolVar(&installRequiresFlag, "install-requires", false, "先安装缺少的依赖应用")
+
+	uninstallCmd.Flags().BoolVar(&uninstallForce, "force", false, "有其他应用依赖或卸载前钩子失败时强制卸载")
+
+	logsCmd.Flags().IntVarP(&logsLines, "lines", "n", 200, "行数")
+
+	updateSourcesCmd.Flags().BoolVar(&updateSourcesDryRun, "dry-run", false, "试运行，只输出差异不更新")
+	updateSourcesCmd.Flags().StringSliceVar(&updateSourcesOverwrite, "overwrite", nil, "需要覆盖的本地修改、来源未知或非仓库来源的应用ID，* 表示全部")
+}
+
+// runCliPre 命令行子命令的预处理：检查工作目录、设置语言，默认不输出调试信息
+func runCliPre(cmd *cobra.Command, args []string) {
+	if cliFormat != "table" && cliFormat != "json" {
+		fmt.Printf("不支持的输出格式: %s\n", cliFormat)
+		os.Exit(1)
+	}
+	if !cmd.Flags().Changed("mode") {
+		mode = global.ModeRelease
+	}
+	runPre(cmd, args)
+	global.Language = strings.ToLower(cliLanguage)
+}
+
+// printJSON 输出 JSON 格式的结果
+func printJSON(data interface{}) {
+	output, _ := json.MarshalIndent(data, "", "  ")
+	fmt.Println(string(output))
+}
+
+// printTable 输出表格，第一行为表头
+func printTable(rows [][]string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	_ = w.Flush()
+}
+
+// exitWithError 输出错误信息并退出
+func exitWithError(stderr string, err error) {
+	if err != nil && err.Error() != stderr {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", stderr, err)
+	} else {
+		fmt.Fprintln(os.Stderr, stderr)
+	}
+	os.Exit(1)
+}
+
+// emptyDash 空值显示为 -
+func emptyDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func runList(*cobra.Command, []string) {
+	apps := models.NewApps(nil)
+	if cliFormat == "json" {
+		printJSON(apps)
+		return
+	}
+
+	rows := [][]string{{"ID", "NAME", "LATEST", "INSTALLED", "STATUS", "UPGRADEABLE"}}
+	for _, app := range apps {
+		rows = append(rows, []string{
+			app.ID,
+			fmt.Sprintf("%v", app.Name),
+			emptyDash(latestVersion(app)),
+			emptyDash(app.Config.InstallVersion),
+			app.Config.Status,
+			fmt.Sprintf("%t", app.Upgradeable),
+		})
+	}
+	printTable(rows)
+}
+
+func runInfo(_ *cobra.Command, args []string) {
+	app, err := models.NewApp(args[0])
+	if err != nil {
+		exitWithError(err.Error(), nil)
+	}
+	if cliFormat == "json" {
+		printJSON(app)
+		return
+	}
+
+	rows := [][]string{
+		{"ID:", app.ID},
+		{"Name:", fmt.Sprintf("%v", app.Name)},
+		{"Description:", emptyDash(fmt.Sprintf("%v", app.Description))},
+		{"Versions:", emptyDash(strings.Join(app.Versions, ", "))},
+		{"Tags:", emptyDash(strings.Join(app.Tags, ", "))},
+		{"Author:", emptyDash(app.Author)},
+		{"Website:", emptyDash(app.Website)},
+		{"Status:", app.Config.Status},
+		{"Installed:", emptyDash(app.Config.InstallVersion)},
+		{"Upgradeable:", fmt.Sprintf("%t", app.Upgradeable)},
+		{"Policy:", app.Config.UpgradePolicy},
+		{"Channel:", app.Config.Channel},
+	}
+	if app.Config.Error != "" {
+		rows = append(rows, []string{"Error:", app.Config.Error})
+	}
+	if app.Config.Degraded != "" {
+		rows = append(rows, []string{"Degraded:", app.Config.Degraded})
+	}
+	if !app.Compatible {
+		rows = append(rows, []string{"Incompatible:", app.IncompatibleReason})
+	}
+	for _, require := range app.Requires {
+		label := require.App
+		if require.Version != "" {
+			label += " (" + require.Version + ")"
+		}
+		if require.Optional {
+			label += " [optional]"
+		}
+		rows = append(rows, []string{"Requires:", label})
+	}
+	for _, field := range app.Fields {
+		value, ok := app.Config.Params[field.Name]
+		if !ok {
+			value = field.Default
+		}
+		rows = append(rows, []string{"Field:", fmt.Sprintf("%s=%v (%v)", field.Name, value, field.Label)})
+	}
+	printTable(rows)
+}
+
+func runInstall(_ *cobra.Command, args []string) {
+	appId := args[0]
+	app, err := models.NewApp(appId)
+	if err != nil {
+		exitWithError(err.Error(), nil)
+	}
+
+	// 组装参数：已安装时的参数 < 字段默认值 < 命令行参数
+	params := map[string]interface{}{}
+	for _, field := range app.Fields {
+		if value, ok := app.Config.Params[field.Name]; ok {
+			params[field.Name] = value
+		} else if field.Default != nil {
+			params[field.Name] = field.Default
+		}
+	}
+	for _, param := range installParams {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok || key == "" {
+			exitWithError(fmt.Sprintf("参数格式错误: %s（应为 key=value）", param), nil)
+		}
+		params[key] = value
+	}
+	for _, field := range app.Fields {
+		if value, ok := params[field.Name]; field.Required && (!ok || fmt.Sprintf("%v", value) == "") {
+			exitWithError(fmt.Sprintf("缺少必填参数: %s（使用 --param %s=value 设置）", field.Name, field.Name), nil)
+		}
+	}
+
+	resources := app.Config.Resources
+	if installCPULimit != "" {
+		resources.CPULimit = installCPULimit
+	}
+	if installMemoryLimit != "" {
+		resources.MemoryLimit = installMemoryLimit
+	}
+
+	req := &models.AppInternalInstallRequest{
+		AppID:           appId,
+		Version:         installVersion,
+		Params:          params,
+		Resources:       resources,
+		InstallRequires: installRequiresFlag,
+	}
+
+	// 服务运行时交给服务安装并等待完成
+	var status, version string
+	if client := localClient(); client != nil {
+		version = localRequest(client, "POST", "/install", req)["version"]
+		status = localWaitFinished(client, appId, 30*time.Minute)
+	} else {
+		// 在当前进程中安装（持有工作目录锁，中断时应用标记为 error）
+		release := lockWorkDir()
+		defer release()
+		var stderr string
+		var err error
+		version, stderr, err = models.InstallApp(req)
+		if version == "" {
+			exitWithError(stderr, err)
+		}
+		status = models.WaitAppFinished(appId, 30*time.Minute)
+	}
+	printActionResult(appId, version, status)
+	if status != "installed" {
+		os.Exit(1)
+	}
+}
+
+func runUninstall(_ *cobra.Command, args []string) {
+	appId := args[0]
+
+	// 服务运行时交给服务卸载并等待完成
+	var status, version string
+	if client := localClient(); client != nil {
+		version = localRequest(client, "GET", fmt.Sprintf("/uninstall/%s?force=%t", url.PathEscape(appId), uninstallForce), nil)["version"]
+		status = localWaitFinished(client, appId, 30*time.Minute)
+	} else {
+		// 在当前进程中卸载（持有工作目录锁，中断时应用标记为 error）
+		release := lockWorkDir()
+		defer release()
+		var stderr string
+		var err error
+		version, stderr, err = models.UninstallApp(appId, uninstallForce)
+		if version == "" {
+			exitWithError(stderr, err)
+		}
+		status = models.WaitAppFinished(appId, 30*time.Minute)
+	}
+	printActionResult(appId, version, status)
+	if status != "not_installed" {
+		os.Exit(1)
+	}
+}
+
+// printActionResult 输出安装或卸载的结果
+func printActionResult(appId, version, status string) {
+	if cliFormat == "json" {
+		printJSON(map[string]string{
+			"id":      appId,
+			"version": version,
+			"status":  status,
+		})
+		return
+	}
+	fmt.Printf("%s %s: %s\n", appId, version, status)
+	if status == "error" {
+		fmt.Printf("查看日志: appstore logs %s\n", appId)
+	}
+}
+
+func runLogs(_ *cobra.Command, args []string) {
+	lines, err := models.GetAppLog(args[0], logsLines)
+	if err != nil {
+		exitWithError("读取日志失败", err)
+	}
+	if cliFormat == "json" {
+		printJSON(map[string]interface{}{
+			"id":   args[0],
+			"logs": lines,
+		})
+		return
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
+
+func runUpdateSources(*cobra.Command, []string) {
+	result, stderr, err := models.SyncSources(updateSourcesDryRun, updateSourcesOverwrite)
+	if result == nil {
+		exitWithError(stderr, err)
+	}
+	if cliFormat == "json" {
+		printJSON(result)
+		return
+	}
+
+	rows := [][]string{{"ID", "DIFF", "LOCAL", "REMOTE", "RESULT", "REASON"}}
+	for _, item := range result.Apps {
+		rows = append(rows, []string{
+			item.ID,
+			item.Status,
+			emptyDash(item.LocalVersion),
+			emptyDash(item.RemoteVersion),
+			emptyDash(item.Result),
+			emptyDash(item.Reason),
+		})
+	}
+	printTable(rows)
+	for _, upgrade := range result.Upgrades {
+		fmt.Printf("%s: %s -> %s (%s)\n", upgrade.ID, upgrade.InstalledVersion, upgrade.LatestVersion, upgrade.Action)
+	}
+	if len(result.Failed) > 0 {
+		os.Exit(1)
+	}
+}
+
+func runStatus(_ *cobra.Command, args []string) {
+	statuses := []appStatus{}
+	for _, app := range models.NewApps(args) {
+		if len(args) == 0 && app.Config.Status == "not_installed" {
+			continue
+		}
+		required, optional := models.FindDependents(app.ID)
+		statuses = append(statuses, appStatus{
+			ID:            app.ID,
+			Version:       app.Config.InstallVersion,
+			Status:        app.Config.Status,
+			InstallAt:     app.Config.InstallAt,
+			LatestVersion: latestVersion(app),
+			Upgradeable:   app.Upgradeable,
+			UpgradePolicy: app.Config.UpgradePolicy,
+			RequiredBy:    append(required, optional...),
+			Error:         app.Config.Error,
+			Degraded:      app.Config.Degraded,
+		})
+	}
+	if cliFormat == "json" {
+		printJSON(statuses)
+		return
+	}
+
+	rows := [][]string{{"ID", "VERSION", "STATUS", "INSTALLED AT", "LATEST", "POLICY", "REQUIRED BY"}}
+	for _, status := range statuses {
+		state := status.Status
+		if status.Degraded != "" {
+			state += " (degraded)"
+		}
+		rows = append(rows, []string{
+			status.ID,
+			emptyDash(status.Version),
+			state,
+			emptyDash(status.InstallAt),
+			emptyDash(status.LatestVersion),
+			status.UpgradePolicy,
+			emptyDash(strings.Join(status.RequiredBy, ", ")),
+		})
+	}
+	printTable(rows)
+}
+
+// appStatus 应用安装状态
+type appStatus struct {
+	ID            string   `json:"id"`
+	Version       string   `json:"version"`
+	Status        string   `json:"status"`
+	InstallAt     string   `json:"install_at"`
+	LatestVersion string   `json:"latest_version"`
+	Upgradeable   bool     `json:"upgradeable"`
+	UpgradePolicy string   `json:"upgrade_policy"`
+	RequiredBy    []string `json:"required_by"` // 依赖此应用的其他已安装应用
+	Error         string   `json:"error,omitempty"`
+	Degraded      string   `json:"degraded,omitempty"` // 容器反复崩溃的原因
+}
+
+// latestVersion 应用可安装的最新版本（按订阅的发布通道、版本范围、固定版本和兼容性过滤）
+func latestVersion(app *models.App) string {
+	version, _ := models.FindLatestVersion(app.ID)
+	return version
+}
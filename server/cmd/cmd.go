@@ -7,19 +7,19 @@ import (
 	"appstore/server/models"
 	"appstore/server/response"
 	"appstore/server/utils"
-	"archive/tar"
-	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
-	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	_ "appstore/server/docs"
@@ -30,7 +30,6 @@ import (
 	"github.com/spf13/cobra"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
-	"gopkg.in/yaml.v3"
 )
 
 // @title           DooTask应用商店API
@@ -65,6 +64,11 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&global.WebDir, "web-dir", "", "前端静态文件目录")
 	rootCmd.PersistentFlags().StringVar(&global.Port, "port", "80", "服务端口")
 	rootCmd.PersistentFlags().StringVar(&mode, "mode", "debug", "运行模式 (debug/release/strict)")
+	rootCmd.PersistentFlags().DurationVar(&global.UpdateInterval, "update-interval", 0, "后台自动更新应用列表的间隔，例如 24h（0 表示不自动更新）")
+	rootCmd.PersistentFlags().StringVar(&global.UpdateWindow, "update-window", "", "允许后台自动更新的时间段，例如 02:00-05:00（为空表示不限制）")
+	rootCmd.PersistentFlags().StringVar(&global.AppDomain, "app-domain", "", "子域名路由的基础域名，例如 apps.example.com")
+	rootCmd.PersistentFlags().StringVar(&global.AppTLSCert, "app-tls-cert", "", "子域名路由的证书路径（nginx容器内的路径）")
+	rootCmd.PersistentFlags().StringVar(&global.AppTLSKey, "app-tls-key", "", "子域名路由的证书私钥路径（nginx容器内的路径）")
 }
 
 func runPre(*cobra.Command, []string) {
@@ -110,6 +114,16 @@ func runPre(*cobra.Command, []string) {
 		}
 	}
 
+	// 检查自动更新时间段
+	if _, _, err := models.ParseUpdateWindow(global.UpdateWindow); err != nil {
+		fmt.Printf("自动更新时间段格式错误: %v\n", err)
+		os.Exit(1)
+	}
+	if (global.AppTLSCert == "") != (global.AppTLSKey == "") {
+		fmt.Println("子域名路由的证书和私钥必须同时设置")
+		os.Exit(1)
+	}
+
 	// 设置工作目录
 	global.WorkDir = absPath
 	if mode == global.ModeDebug {
@@ -118,6 +132,18 @@ func runPre(*cobra.Command, []string) {
 }
 
 func runServer(*cobra.Command, []string) {
+	// 获取工作目录锁（命令行正在安装或卸载时等待完成），服务运行期间持有
+	release, err := models.LockWorkDir(false)
+	if errors.Is(err, models.ErrWorkDirLocked) {
+		fmt.Println("等待其他进程完成安装或卸载...")
+		release, err = models.LockWorkDir(true)
+	}
+	if err != nil {
+		fmt.Printf("获取工作目录锁失败: %v\n", err)
+		os.Exit(1)
+	}
+	defer release()
+
 	// 创建默认的gin路由引擎
 	r := gin.Default()
 
@@ -144,23 +170,31 @@ func runServer(*cobra.Command, []string) {
 		v1.GET("/readme/:appId", strictMiddleware, routeAppReadme)                                         // 获取应用自述文件
 		v1.Match([]string{"GET", "HEAD"}, "/download/:appId/*version", strictMiddleware, routeAppDownload) // 下载应用压缩包
 		v1.Match([]string{"GET", "HEAD"}, "/sources/package", strictMiddleware, routeSourcesPackage)       // 下载应用商店资源包
+		v1.Match([]string{"GET", "HEAD"}, "/sources/index", strictMiddleware, routeSourcesIndex)           // 获取应用商店仓库索引
 
 		// 始终不需要身份
-		v1.GET("/asset/:appId/*assetPath", routeAppAsset) // 查看应用资源
+		v1.GET("/asset/:appId/*assetPath", routeAppAsset)  // 查看应用资源
+		v1.GET("/schema/config.json", routeManifestSchema) // 获取应用配置文件的 JSON Schema
 
 		// 内部使用接口
 		internal := v1.Group("/internal")
 		{
 			// 需要管理员
-			internal.POST("/install", adminMiddleware, routeInternalInstall)             // 安装应用
-			internal.GET("/uninstall/:appId", adminMiddleware, routeInternalUninstall)   // 卸载应用
-			internal.GET("/apps/update", adminMiddleware, routeInternalUpdateList)       // 更新应用列表
-			internal.POST("/apps/download", adminMiddleware, routeInternalDownloadByURL) // 通过URL下载应用
-			internal.POST("/apps/upload", adminMiddleware, routeInternalUpload)          // 上传本地应用
+			internal.POST("/install", adminMiddleware, routeInternalInstall)                // 安装应用
+			internal.GET("/uninstall/:appId", adminMiddleware, routeInternalUninstall)      // 卸载应用
+			internal.GET("/apps/update", adminMiddleware, routeInternalUpdateList)          // 更新应用列表
+			internal.GET("/apps/update/status", adminMiddleware, routeInternalUpdateStatus) // 后台更新应用列表状态
+			internal.POST("/apps/download", adminMiddleware, routeInternalDownloadByURL)    // 通过URL下载应用
+			internal.POST("/apps/upload", adminMiddleware, routeInternalUpload)             // 上传本地应用
+			internal.POST("/upgrade/policy", adminMiddleware, routeInternalUpgradePolicy)   // 设置自动升级策略
+			internal.POST("/upgrade/pin", adminMiddleware, routeInternalUpgradePin)         // 固定应用版本
+			internal.POST("/upgrade/channel", adminMiddleware, routeInternalUpgradeChannel) // 设置发布通道
+			internal.GET("/upgrade/plan/:appId", adminMiddleware, routeInternalUpgradePlan) // 获取升级计划
 
 			// 需要会员
-			internal.GET("/installed", authMiddleware, routeInternalInstalled) // 获取已安装应用列表
-			internal.GET("/log/:appId", authMiddleware, routeInternalLog)      // 获取应用日志
+			internal.GET("/installed", authMiddleware, routeInternalInstalled)    // 获取已安装应用列表
+			internal.GET("/runtime/:appId", authMiddleware, routeInternalRuntime) // 获取应用运行状态
+			internal.GET("/log/:appId", authMiddleware, routeInternalLog)         // 获取应用日志
 		}
 	}
 
@@ -170,12 +204,31 @@ func runServer(*cobra.Command, []string) {
 	// 添加健康检查路由
 	r.GET("/health", routeHealth)
 
-	// 启动检测容器状态守护
-	go models.StartCheckContainerStatusDaemon()
+	// 收到退出信号时停止容器守护并关闭服务器
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 启动本地接口（命令行通过工作目录下的 unix socket 把安装、卸载交给服务执行）
+	go startLocalServer(ctx)
+
+	// 启动容器守护（订阅Docker事件，自动重启异常的容器）
+	go models.StartContainerSupervisor(ctx)
+
+	// 启动后台定时更新应用列表
+	go models.StartSourcesUpdateScheduler()
+
+	// 启动自动升级守护
+	go models.StartAutoUpgradeDaemon()
 
 	// 启动服务器
-	err := r.Run(":" + global.Port)
-	if err != nil {
+	server := &http.Server{Addr: ":" + global.Port, Handler: r}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		fmt.Printf("启动服务器失败: %v\n", err)
 		os.Exit(1)
 	}
@@ -209,7 +262,7 @@ func routeList(c *gin.Context) {
 }
 
 // @Summary 获取应用详情
-// @Description 获取指定应用的详细信息
+// @Description 获取指定应用的详细信息（包含各版本更新日志，已安装时包含各服务的运行状态和健康，容器、镜像等详细信息见 /internal/runtime/{appId}）
 // @Tags 应用
 // @Accept json
 // @Produce json
@@ -223,22 +276,45 @@ func routeAppOne(c *gin.Context) {
 		response.ErrorWithDetail(c, global.CodeError, i18n.T("GetAppDetailFailed"), err)
 		return
 	}
+	app.Changelogs = models.GetAppChangelogs(app)
+	if runtime := models.GetAppRuntime(appId); runtime != nil {
+		app.Runtime = runtime.PublicState()
+	}
 	response.SuccessWithData(c, app)
 }
 
 // @Summary 获取应用自述文件
-// @Description 获取指定应用的README文件内容
+// @Description 获取指定应用的README文件内容、指定版本（默认最新版本）的更新日志，以及两个版本之间（默认从已安装版本到最新版本）的更新日志
 // @Tags 应用
 // @Accept json
 // @Produce json
 // @Param appId path string true "应用ID"
-// @Success 200 {object} response.Response{data=map[string]string}
+// @Param version query string false "版本号，默认最新版本"
+// @Param from query string false "起始版本（不含），默认已安装的版本"
+// @Param to query string false "目标版本（含），默认最新版本"
+// @Success 200 {object} response.Response{data=map[string]interface{}}
 // @Router /readme/{appId} [get]
 func routeAppReadme(c *gin.Context) {
 	appId := c.Param("appId")
-	response.SuccessWithData(c, gin.H{
-		"content": models.GetReadme(appId),
-	})
+	data := gin.H{
+		"content":    models.GetReadme(appId),
+		"changelog":  "",
+		"changelogs": []models.VersionChangelog{},
+	}
+
+	app, err := models.NewApp(appId)
+	if err == nil && len(app.Versions) > 0 {
+		version := c.DefaultQuery("version", app.Versions[0])
+		data["changelog"] = models.GetVersionChangelog(app, version)
+
+		from := c.DefaultQuery("from", app.Config.InstallVersion)
+		to := c.DefaultQuery("to", app.Versions[0])
+		if from != "" {
+			data["changelogs"] = models.GetAggregatedChangelogs(app, from, to)
+		}
+	}
+
+	response.SuccessWithData(c, data)
 }
 
 // routeAppDownload 处理应用下载请求
@@ -264,7 +340,6 @@ func routeAppDownload(c *gin.Context) {
 
 	var downloadFilename string
 	effectiveVersion := versionParam
-	versionRegex := regexp.MustCompile(`^v?\d+(\.\d+){1,2}$`)
 
 	if versionParam == "latest" {
 		latestV, err := models.FindLatestVersion(cleanedAppId)
@@ -279,7 +354,7 @@ func routeAppDownload(c *gin.Context) {
 		downloadFilename = fmt.Sprintf("%s-%s.tar.gz", cleanedAppId, effectiveVersion)
 	} else if versionParam != "" {
 		cleanedVersion := filepath.Clean(effectiveVersion)
-		if cleanedVersion != effectiveVersion || strings.Contains(cleanedVersion, "..") || strings.Contains(cleanedVersion, "/") || strings.Contains(cleanedVersion, "\\") || !versionRegex.MatchString(cleanedVersion) {
+		if cleanedVersion != effectiveVersion || strings.Contains(cleanedVersion, "..") || strings.Contains(cleanedVersion, "/") || strings.Contains(cleanedVersion, "\\") || !utils.IsValidVersion(cleanedVersion) {
 			c.String(http.StatusBadRequest, i18n.T("InvalidVersionFormat"))
 			return
 		}
@@ -295,6 +370,25 @@ func routeAppDownload(c *gin.Context) {
 		downloadFilename = fmt.Sprintf("%s.tar.gz", cleanedAppId)
 	}
 
+	// 指定版本时跳过其他版本目录
+	skipVersion := func(relPath string, info os.FileInfo) bool {
+		if effectiveVersion == "" || !info.IsDir() {
+			return false
+		}
+		parts := strings.Split(relPath, string(filepath.Separator))
+		return utils.IsValidVersion(parts[0]) && parts[0] != effectiveVersion
+	}
+
+	// 内容摘要作为ETag，支持条件请求
+	if digest, err := utils.DirDigest(appRootPath, skipVersion); err == nil {
+		etag := "\"" + digest.Digest + "\""
+		c.Header("ETag", etag)
+		if models.MatchETag(c.GetHeader("If-None-Match"), etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
 	c.Header("Content-Description", "File Transfer")
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", downloadFilename))
 	c.Header("Content-Type", "application/gzip")
@@ -305,56 +399,8 @@ func routeAppDownload(c *gin.Context) {
 		return
 	}
 
-	gw := gzip.NewWriter(c.Writer)
-	defer gw.Close()
-	tw := tar.NewWriter(gw)
-	defer tw.Close()
-
-	err := filepath.Walk(appRootPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		relPath, err := filepath.Rel(appRootPath, path)
-		if err != nil {
-			return err
-		}
-		if relPath == "." {
-			return nil
-		}
-
-		if effectiveVersion != "" {
-			parts := strings.Split(relPath, string(filepath.Separator))
-			if len(parts) > 0 && info.IsDir() && versionRegex.MatchString(parts[0]) {
-				if parts[0] != effectiveVersion {
-					return filepath.SkipDir
-				}
-			}
-		}
-
-		header, err := tar.FileInfoHeader(info, info.Name())
-		if err != nil {
-			return err
-		}
-		header.Name = filepath.ToSlash(relPath)
-
-		if err := tw.WriteHeader(header); err != nil {
-			return err
-		}
-
-		if !info.IsDir() {
-			f, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-			if _, err := io.Copy(tw, f); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-
+	// 写入应用包（相同内容生成相同的应用包）
+	err := models.WriteAppPackage(c.Writer, appRootPath, skipVersion)
 	if err != nil {
 		fmt.Printf("创建 %s (版本: %s) 的 tar.gz 文件时发生错误: %v\n", cleanedAppId, effectiveVersion, err)
 	}
@@ -378,113 +424,113 @@ func routeInternalInstall(c *gin.Context) {
 		return
 	}
 
-	// 处理latest版本
-	if req.Version == "latest" {
-		latestV, err := models.FindLatestVersion(req.AppID)
-		if err != nil {
-			response.ErrorWithDetail(c, global.CodeError, i18n.T("CannotDetermineLatestVersionSingle", req.AppID), err)
-			return
-		}
-		req.Version = latestV
+	// 安装应用
+	version, stderr, err := models.InstallApp(&req)
+	if version == "" {
+		response.ErrorWithDetail(c, global.CodeError, stderr, err)
+		return
 	}
 
-	// 获取当前应用配置
-	appConfig := models.GetAppConfig(req.AppID)
+	response.SuccessWithMsg(c, i18n.T("AppInstalling"))
+}
 
-	// 判断当前状态
-	if appConfig.Status == "installing" || appConfig.Status == "uninstalling" {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("AppIsRunning"), nil)
+// @Summary 设置自动升级策略
+// @Description 设置已安装应用的自动升级策略（manual、notify、auto-patch、auto-minor、auto-all）和维护时间段，更新应用列表后按策略自动升级
+// @Tags 内部接口
+// @Accept json
+// @Produce json
+// @Param request body models.AppInternalUpgradePolicyRequest true "自动升级策略"
+// @Success 200 {object} response.Response{data=models.AppConfig}
+// @Router /internal/upgrade/policy [post]
+func routeInternalUpgradePolicy(c *gin.Context) {
+	var req models.AppInternalUpgradePolicyRequest
+	if err := response.CheckBindAndValidate(&req, c); err != nil {
 		return
 	}
 
-	// 检查是否需要先卸载
-	if appConfig.Status == "installed" && appConfig.InstallVersion != "" {
-		app, err := models.NewApp(req.AppID)
-		if err != nil {
-			response.ErrorWithDetail(c, global.CodeError, i18n.T("GetAppDetailFailed"), err)
-			return
-		}
-		for _, require := range app.RequireUninstalls {
-			if utils.CheckVersionRequirement(appConfig.InstallVersion, require.Operator, require.Version) {
-				reason := require.Reason.(string)
-				message := i18n.T("NeedUninstallBeforeUpdateSingle", req.Version)
-				if reason == "" {
-					message = i18n.T("NeedUninstallBeforeUpdate", map[string]interface{}{
-						"version": req.Version,
-						"reason":  reason,
-					})
-				}
-				response.ErrorWithDetail(c, global.CodeError, message, errors.New(reason))
-				return
-			}
-		}
+	appConfig, stderr, err := models.SetUpgradePolicy(&req)
+	if appConfig == nil {
+		response.ErrorWithDetail(c, global.CodeError, stderr, err)
+		return
 	}
+	response.SuccessWithData(c, appConfig)
+}
 
-	// 创建配置目录
-	configDir := filepath.Join(global.WorkDir, "config", req.AppID)
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("CreateConfigDirFailed"), err)
+// @Summary 固定应用版本
+// @Description 固定已安装应用的版本（不提示升级，也不自动升级），或设置允许升级的版本范围（例如 ~1.2、^2.0、>=1.0 <2.0）
+// @Tags 内部接口
+// @Accept json
+// @Produce json
+// @Param request body models.AppInternalPinRequest true "固定版本参数"
+// @Success 200 {object} response.Response{data=models.AppConfig}
+// @Router /internal/upgrade/pin [post]
+func routeInternalUpgradePin(c *gin.Context) {
+	var req models.AppInternalPinRequest
+	if err := response.CheckBindAndValidate(&req, c); err != nil {
 		return
 	}
 
-	// 更新配置
-	appConfig.InstallVersion = req.Version
-	appConfig.Params = req.Params
-	appConfig.Resources = req.Resources
-
-	// 保存配置到文件
-	if err := models.SaveAppConfig(req.AppID, appConfig); err != nil {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("SaveConfigFailed"), err)
+	appConfig, stderr, err := models.SetAppPin(&req)
+	if appConfig == nil {
+		response.ErrorWithDetail(c, global.CodeError, stderr, err)
 		return
 	}
+	response.SuccessWithData(c, appConfig)
+}
 
-	// 生成docker-compose.yml文件
-	if err := models.GenerateDockerCompose(req.AppID, req.Version, appConfig); err != nil {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("GenerateDockerComposeFailed"), err)
+// @Summary 设置发布通道
+// @Description 设置应用订阅的发布通道（stable、beta、dev），应用详情、最新版本和升级只考虑通道内的版本
+// @Tags 内部接口
+// @Accept json
+// @Produce json
+// @Param request body models.AppInternalChannelRequest true "发布通道参数"
+// @Success 200 {object} response.Response{data=models.AppConfig}
+// @Router /internal/upgrade/channel [post]
+func routeInternalUpgradeChannel(c *gin.Context) {
+	var req models.AppInternalChannelRequest
+	if err := response.CheckBindAndValidate(&req, c); err != nil {
 		return
 	}
 
-	// 生成nginx配置文件
-	if err := models.GenerateNginxConfig(req.AppID, req.Version, appConfig); err != nil {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("GenerateNginxConfigFailed"), err)
+	appConfig, stderr, err := models.SetAppChannel(&req)
+	if appConfig == nil {
+		response.ErrorWithDetail(c, global.CodeError, stderr, err)
 		return
 	}
+	response.SuccessWithData(c, appConfig)
+}
 
-	// 执行docker-compose up命令
-	if err := models.RunDockerCompose(req.AppID, "up"); err != nil {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("StartAppFailed"), err)
+// @Summary 获取升级计划
+// @Description 获取已安装应用的升级计划：候选目标版本、需要先卸载的要求、新增和已移除的字段、各版本更新日志
+// @Tags 内部接口
+// @Accept json
+// @Produce json
+// @Param appId path string true "应用ID"
+// @Success 200 {object} response.Response{data=models.UpgradePlan}
+// @Router /internal/upgrade/plan/{appId} [get]
+func routeInternalUpgradePlan(c *gin.Context) {
+	plan, stderr, err := models.GetUpgradePlan(c.Param("appId"))
+	if plan == nil {
+		response.ErrorWithDetail(c, global.CodeError, stderr, err)
 		return
 	}
-
-	response.SuccessWithMsg(c, i18n.T("AppInstalling"))
+	response.SuccessWithData(c, plan)
 }
 
 // @Summary 卸载应用
-// @Description 卸载指定的应用
+// @Description 卸载指定的应用（已安装或安装失败的应用），有其他已安装应用依赖此应用时需要 force=true 才能卸载
 // @Tags 内部接口
 // @Accept json
 // @Produce json
 // @Param appId path string true "应用ID"
+// @Param force query bool false "有其他应用依赖或卸载前钩子失败时是否强制卸载"
 // @Success 200 {object} response.Response
 // @Router /internal/uninstall/{appId} [get]
 func routeInternalUninstall(c *gin.Context) {
-	appId := c.Param("appId")
-
-	// 获取当前应用配置
-	appConfig := models.GetAppConfig(appId)
-
-	// 判断当前状态
-	if appConfig.Status != "installed" {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("AppNotInstalled"), nil)
-		return
-	}
-
-	// 删除nginx配置
-	models.DeleteNginxConfig(appId)
-
-	// 执行docker-compose down命令
-	if err := models.RunDockerCompose(appId, "down"); err != nil {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("UninstallAppFailed"), err)
+	// 卸载应用（先执行卸载钩子并删除nginx配置）
+	version, stderr, err := models.UninstallApp(c.Param("appId"), c.Query("force") == "true")
+	if version == "" {
+		response.ErrorWithDetail(c, global.CodeError, stderr, err)
 		return
 	}
 
@@ -492,26 +538,52 @@ func routeInternalUninstall(c *gin.Context) {
 }
 
 // @Summary 获取已安装应用列表
-// @Description 获取所有已安装的应用列表
+// @Description 获取所有已安装的应用列表，包含各服务的运行状态（容器状态、健康、重启次数、运行时长、镜像及摘要，缓存5秒）
 // @Tags 内部接口
 // @Accept json
 // @Produce json
 // @Success 200 {object} response.Response{data=[]models.AppInternalInstalledResponse}
 // @Router /internal/installed [get]
 func routeInternalInstalled(c *gin.Context) {
-	apps := models.NewApps(nil)
-	resp := []models.AppInternalInstalledResponse{}
-	for _, app := range apps {
+	installed := []*models.App{}
+	for _, app := range models.NewApps(nil) {
 		if app.Config.Status == "installed" {
-			resp = append(resp, models.AppInternalInstalledResponse{
-				ID:        app.ID,
-				MenuItems: app.MenuItems,
-			})
+			installed = append(installed, app)
 		}
 	}
+	appIds := make([]string, 0, len(installed))
+	for _, app := range installed {
+		appIds = append(appIds, app.ID)
+	}
+	runtimes := models.GetAppsRuntime(appIds)
+	resp := []models.AppInternalInstalledResponse{}
+	for _, app := range installed {
+		resp = append(resp, models.AppInternalInstalledResponse{
+			ID:        app.ID,
+			MenuItems: app.MenuItems,
+			Runtime:   runtimes[app.ID],
+		})
+	}
 	response.SuccessWithData(c, resp)
 }
 
+// @Summary 获取应用运行状态
+// @Description 获取指定应用各服务的运行状态（容器状态、健康、重启次数、运行时长、镜像及摘要，缓存5秒）
+// @Tags 内部接口
+// @Accept json
+// @Produce json
+// @Param appId path string true "应用ID"
+// @Success 200 {object} response.Response{data=models.AppRuntime}
+// @Router /internal/runtime/{appId} [get]
+func routeInternalRuntime(c *gin.Context) {
+	runtime := models.GetAppRuntime(c.Param("appId"))
+	if runtime == nil {
+		response.ErrorWithDetail(c, global.CodeError, i18n.T("AppNotInstalledError"), nil)
+		return
+	}
+	response.SuccessWithData(c, runtime)
+}
+
 // @Summary 获取应用日志
 // @Description 获取指定应用的运行日志
 // @Tags 内部接口
@@ -541,143 +613,39 @@ func routeInternalLog(c *gin.Context) {
 }
 
 // @Summary 更新应用列表
-// @Description 从远程仓库更新应用列表，给 DooTask 内部应用商店使用
+// @Description 从远程仓库增量更新应用列表（只下载摘要变化的应用，远程不支持仓库索引时下载完整资源包），给 DooTask 内部应用商店使用，返回每个应用的差异（new、unchanged、updated、modified）
 // @Tags 内部接口
 // @Accept json
 // @Produce json
-// @Success 200 {object} response.Response
+// @Param dry_run query bool false "试运行，只返回差异不更新"
+// @Param overwrite query string false "需要覆盖的本地修改、来源未知或非仓库来源的应用ID，多个用逗号分隔，* 表示全部"
+// @Success 200 {object} response.Response{data=models.SourcesSyncResult}
 // @Router /internal/apps/update [get]
 func routeInternalUpdateList(c *gin.Context) {
-	// 临时目录
-	tempDir := filepath.Join(global.WorkDir, "temp", "sources")
-	tarFile := filepath.Join(tempDir, "sources.tar.gz")
-
-	// 清空临时目录
-	if utils.IsDirExists(tempDir) {
-		if err := os.RemoveAll(tempDir); err != nil {
-			response.ErrorWithDetail(c, global.CodeError, i18n.T("CleanTempDirFailed"), err)
-			return
-		}
-	}
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("CreateTempDirFailed"), err)
-		return
-	}
-
-	// 下载源列表
-	resp, err := http.Get("https://appstore.dootask.com/api/v1/sources/package")
-	if err != nil {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("DownloadSourceListFailed"), err)
-		return
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	var overwrite []string
+	if c.Query("overwrite") != "" {
+		overwrite = strings.Split(c.Query("overwrite"), ",")
 	}
-	defer resp.Body.Close()
-
-	// 保存tar.gz文件
-	tarData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("ReadDownloadDataFailed"), err)
-		return
-	}
-	if err := os.WriteFile(tarFile, tarData, 0644); err != nil {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("SaveTarFileFailed"), err)
-		return
-	}
-
-	// 解压tar.gz文件
-	if err := utils.UnTarGz(tarFile, tempDir); err != nil {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("ExtractFileFailed"), err)
-		return
-	}
-	os.Remove(tarFile)
-
-	// 遍历目录
-	entries, err := os.ReadDir(tempDir)
-	if err != nil {
-		response.ErrorWithDetail(c, global.CodeError, i18n.T("ReadDirectoryFailed"), err)
+	results, stderr, err := models.SyncSources(dryRun, overwrite)
+	if results == nil {
+		response.ErrorWithDetail(c, global.CodeError, stderr, err)
 		return
 	}
-
-	results := struct {
-		Success []map[string]string `json:"success"`
-		Failed  []map[string]string `json:"failed"`
-	}{
-		Success: make([]map[string]string, 0),
-		Failed:  make([]map[string]string, 0),
-	}
-
-	for _, entry := range entries {
-		// 跳过当前目录、父目录和隐藏文件
-		if entry.Name() == "." || entry.Name() == ".." || strings.HasPrefix(entry.Name(), ".") {
-			continue
-		}
-
-		appId := entry.Name()
-		sourceDir := filepath.Join(tempDir, appId)
-		if !entry.IsDir() {
-			continue
-		}
-
-		// 检查config.yml文件
-		configFile := filepath.Join(sourceDir, "config.yml")
-		if !utils.IsFileExists(configFile) {
-			results.Failed = append(results.Failed, map[string]string{
-				"id":     appId,
-				"reason": i18n.T("ConfigYmlNotFound"),
-			})
-			continue
-		}
-
-		// 解析配置文件
-		configData, err := os.ReadFile(configFile)
-		if err != nil {
-			results.Failed = append(results.Failed, map[string]string{
-				"id":     appId,
-				"reason": i18n.T("ReadConfigFailed", err.Error()),
-			})
-			continue
-		}
-
-		var config map[string]interface{}
-		if err := yaml.Unmarshal(configData, &config); err != nil {
-			results.Failed = append(results.Failed, map[string]string{
-				"id":     appId,
-				"reason": i18n.T("YamlParseFailed", err.Error()),
-			})
-			continue
-		}
-
-		// 检查name字段
-		if _, ok := config["name"]; !ok {
-			results.Failed = append(results.Failed, map[string]string{
-				"id":     appId,
-				"reason": i18n.T("InvalidConfig"),
-			})
-			continue
-		}
-
-		// 使用目录名作为应用名称
-		targetDir := filepath.Join(global.WorkDir, "apps", appId)
-
-		// 复制目录
-		if err := utils.CopyDir(sourceDir, targetDir, true); err != nil {
-			results.Failed = append(results.Failed, map[string]string{
-				"id":     appId,
-				"reason": i18n.T("CopyFileFailed", err.Error()),
-			})
-			continue
-		}
-
-		results.Success = append(results.Success, map[string]string{
-			"id": appId,
-		})
-	}
-
-	// 清理临时目录
-	os.RemoveAll(tempDir)
-
 	response.SuccessWithData(c, results)
 }
 
+// @Summary 后台更新应用列表状态
+// @Description 获取后台定时更新应用列表的配置、最近的更新记录以及新增的可升级应用
+// @Tags 内部接口
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=models.SourcesUpdateStatus}
+// @Router /internal/apps/update/status [get]
+func routeInternalUpdateStatus(c *gin.Context) {
+	response.SuccessWithData(c, models.GetSourcesUpdateStatus())
+}
+
 // @Summary 通过URL下载应用
 // @Description 通过URL下载并安装应用
 // @Tags 内部接口
@@ -724,9 +692,14 @@ func routeInternalDownloadByURL(c *gin.Context) {
 
 	// 判断URL类型
 	isGit := strings.HasSuffix(req.URL, ".git") || strings.Contains(req.URL, "github.com") || strings.Contains(req.URL, "gitlab.com")
+	source := &models.AppSource{
+		Type: models.AppSourceURL,
+		URL:  req.URL,
+	}
 
 	// 下载或克隆
 	if isGit {
+		source.Type = models.AppSourceGit
 		// 克隆Git仓库
 		cmd := exec.Command("git", "clone", "--depth=1", req.URL, tempDir)
 		if err := cmd.Run(); err != nil {
@@ -763,7 +736,7 @@ func routeInternalDownloadByURL(c *gin.Context) {
 	}
 
 	// 检查应用是否符合要求
-	output, stderr, err := models.CheckAppCompliance(appId, tempDir)
+	output, stderr, err := models.CheckAppCompliance(appId, tempDir, source)
 	if output == "" {
 		response.ErrorWithDetail(c, global.CodeError, stderr, err)
 		return
@@ -782,6 +755,7 @@ func routeInternalDownloadByURL(c *gin.Context) {
 // @Produce json
 // @Param file formData file true "应用文件"
 // @Param appid formData string false "应用ID，留空则从文件名自动提取"
+// @Param digest formData string false "应用包的sha256摘要（sha256:xxx），不为空时校验上传的文件"
 // @Success 200 {object} response.Response{data=map[string]string}
 // @Router /internal/apps/upload [post]
 func routeInternalUpload(c *gin.Context) {
@@ -816,6 +790,18 @@ func routeInternalUpload(c *gin.Context) {
 		return
 	}
 
+	// 校验应用包摘要
+	if digest := c.PostForm("digest"); digest != "" {
+		if actual, ok := models.MatchFileDigest(filePath, digest); !ok {
+			os.RemoveAll(tempDir)
+			response.ErrorWithDetail(c, global.CodeError, i18n.T("PackageDigestMismatch", map[string]interface{}{
+				"expected": digest,
+				"actual":   actual,
+			}), nil)
+			return
+		}
+	}
+
 	// 检查文件类型并解压
 	output, stderr, err := models.CheckFileTypeAndUnzip(filePath, tempDir)
 	if output == "" {
@@ -824,7 +810,10 @@ func routeInternalUpload(c *gin.Context) {
 	}
 
 	// 检查应用是否符合要求
-	output, stderr, err = models.CheckAppCompliance(appId, tempDir)
+	output, stderr, err = models.CheckAppCompliance(appId, tempDir, &models.AppSource{
+		Type:     models.AppSourceUpload,
+		Filename: file.Filename,
+	})
 	if output == "" {
 		response.ErrorWithDetail(c, global.CodeError, stderr, err)
 		return
@@ -837,125 +826,74 @@ func routeInternalUpload(c *gin.Context) {
 }
 
 // @Summary 应用商店源列表
-// @Description 获取应用商店源列表压缩包
+// @Description 获取应用商店源列表压缩包，按 apps 目录内容摘要缓存，支持 ETag、Last-Modified 条件请求与 Range 断点续传
 // @Tags 资源
 // @Accept json
 // @Produce application/gzip
 // @Success 200 {file} binary "sources.tar.gz"
+// @Success 206 {file} binary "sources.tar.gz"
+// @Success 304 "资源包未变化"
 // @Router /sources/package [get]
 func routeSourcesPackage(c *gin.Context) {
-	// 获取当前日期作为文件名
-	currentDate := time.Now().Format("20060102")
-	sourcesDir := filepath.Join(global.WorkDir, "temp", "sources_package")
-	tarFile := filepath.Join(sourcesDir, currentDate+".tar.gz")
+	// 获取资源包（按apps目录内容摘要缓存）
+	pkg, stderr, err := models.BuildSourcesPackage()
+	if pkg == nil {
+		if err != nil {
+			fmt.Printf("生成应用商店资源包失败: %v\n", err)
+		}
+		c.String(http.StatusInternalServerError, stderr)
+		return
+	}
+
+	defer pkg.File.Close()
 
 	// 设置响应头
 	c.Header("Content-Description", "File Transfer")
 	c.Header("Content-Disposition", "attachment; filename=sources.tar.gz")
 	c.Header("Content-Type", "application/gzip")
-	if c.Request.Method == "HEAD" {
-		c.Status(http.StatusOK)
-		return
-	}
-
-	// 如果文件已存在，直接下载
-	if utils.IsFileExists(tarFile) {
-		c.File(tarFile)
-		return
-	}
+	c.Header("ETag", "\""+pkg.Digest+"\"")
 
-	// 获取apps目录
-	appsDir := filepath.Join(global.WorkDir, "apps")
-	if !utils.IsDirExists(appsDir) {
-		c.String(http.StatusInternalServerError, i18n.T("AppsDirNotFound"))
-		return
-	}
+	// 发送文件（处理 HEAD、Range、If-None-Match、If-Modified-Since）
+	http.ServeContent(c.Writer, c.Request, "sources.tar.gz", pkg.UpdatedAt, pkg.File)
+}
 
-	// 获取所有子目录
-	appIds, err := utils.GetSubDirs(appsDir)
+// @Summary 应用商店仓库索引
+// @Description 获取应用商店仓库索引（每个应用的最新版本、各版本摘要、大小、更新时间），支持 If-None-Match 条件请求，应用包变化前使用缓存
+// @Tags 资源
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.SourcesIndex
+// @Success 304 "索引未变化"
+// @Router /sources/index [get]
+func routeSourcesIndex(c *gin.Context) {
+	index, err := models.BuildSourcesIndex()
 	if err != nil {
-		c.String(http.StatusInternalServerError, i18n.T("GetAppListFailed"))
+		c.String(http.StatusInternalServerError, i18n.T("GetSourcesIndexFailed"))
 		return
 	}
 
-	// 清空并创建临时目录
-	if utils.IsDirExists(sourcesDir) {
-		if err := os.RemoveAll(sourcesDir); err != nil {
-			c.String(http.StatusInternalServerError, i18n.T("CleanTempDirFailed"))
-			return
-		}
-	}
-	if err := os.MkdirAll(sourcesDir, 0755); err != nil {
-		c.String(http.StatusInternalServerError, i18n.T("CreateTempDirFailed"))
+	etag := index.ETag()
+	c.Header("ETag", etag)
+	if models.MatchETag(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
 		return
 	}
-
-	// 创建tar.gz文件
-	file, err := os.Create(tarFile)
-	if err != nil {
-		c.String(http.StatusInternalServerError, i18n.T("CreateZipFileFailed"))
+	if c.Request.Method == "HEAD" {
+		c.Status(http.StatusOK)
 		return
 	}
-	defer file.Close()
-
-	// 创建gzip写入器
-	gw := gzip.NewWriter(file)
-	defer gw.Close()
-
-	// 创建tar写入器
-	tw := tar.NewWriter(gw)
-	defer tw.Close()
-
-	// 遍历每个应用目录
-	for _, appId := range appIds {
-		appDir := filepath.Join(appsDir, appId)
-		err := filepath.Walk(appDir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-
-			// 获取相对路径
-			relPath, err := filepath.Rel(appsDir, path)
-			if err != nil {
-				return err
-			}
-
-			// 创建tar文件头
-			header, err := tar.FileInfoHeader(info, info.Name())
-			if err != nil {
-				return err
-			}
-			header.Name = filepath.ToSlash(relPath)
-
-			// 写入文件头
-			if err := tw.WriteHeader(header); err != nil {
-				return err
-			}
 
-			// 如果是目录，跳过写入内容
-			if info.IsDir() {
-				return nil
-			}
-
-			// 如果是文件，写入文件内容
-			file, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			defer file.Close()
-
-			_, err = io.Copy(tw, file)
-			return err
-		})
-
-		if err != nil {
-			c.String(http.StatusInternalServerError, i18n.T("PackageFileFailed"))
-			return
-		}
-	}
+	c.JSON(http.StatusOK, index)
+}
 
-	// 发送文件
-	c.File(tarFile)
+// @Summary 获取应用配置文件的 JSON Schema
+// @Description 获取应用配置文件（config.yml）的 JSON Schema，可用于编辑器校验和自动补全
+// @Tags 应用
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /schema/config.json [get]
+func routeManifestSchema(c *gin.Context) {
+	c.Data(http.StatusOK, "application/schema+json; charset=utf-8", models.ManifestSchema)
 }
 
 // routeAppAsset 处理应用资源请求
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"appstore/server/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintFormat string
+	lintSchema bool
+	lintCmd    = &cobra.Command{
+		Use:   "lint [应用目录...]",
+		Short: "检查应用目录是否符合应用商店规范",
+		Long:  "检查应用目录的 config.yml（不允许未知配置项）、每个版本的 docker-compose.yml、nginx.conf、hooks.yml、占位符、图标和 README，存在错误时退出码为 1",
+		Run:   runLint,
+	}
+)
+
+func init() {
+	lintCmd.Flags().StringVar(&lintFormat, "format", "text", "输出格式 (text/json)")
+	lintCmd.Flags().BoolVar(&lintSchema, "schema", false, "输出 config.yml 的 JSON Schema")
+	rootCmd.AddCommand(lintCmd)
+}
+
+func runLint(cmd *cobra.Command, args []string) {
+	// 输出 JSON Schema
+	if lintSchema {
+		fmt.Println(string(models.ManifestSchema))
+		return
+	}
+	if len(args) == 0 {
+		_ = cmd.Usage()
+		os.Exit(1)
+	}
+	if lintFormat != "text" && lintFormat != "json" {
+		fmt.Printf("不支持的输出格式: %s\n", lintFormat)
+		os.Exit(1)
+	}
+
+	// 检查应用目录
+	results := []*models.LintResult{}
+	valid := true
+	for _, dir := range args {
+		result := models.LintApp(dir)
+		results = append(results, result)
+		valid = valid && result.Valid
+	}
+
+	// 输出结果
+	if lintFormat == "json" {
+		data, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		for _, result := range results {
+			fmt.Printf("%s (%d versions): %d error(s), %d warning(s)\n", result.AppID, len(result.Versions), result.Errors, result.Warnings)
+			for _, issue := range result.Issues {
+				file := issue.File
+				if file == "" {
+					file = "-"
+				}
+				fmt.Printf("  %-8s %-24s %-28s %s\n", issue.Level, file, issue.Code, issue.Message)
+			}
+		}
+	}
+
+	if !valid {
+		os.Exit(1)
+	}
+}
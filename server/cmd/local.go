@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"appstore/server/global"
+	"appstore/server/i18n"
+	"appstore/server/models"
+	"appstore/server/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// localSocketFile 本地接口的 unix socket（工作目录下），服务运行时命令行通过它把安装、卸载交给服务执行
+func localSocketFile() string {
+	return filepath.Join(global.WorkDir, "config", ".appstore.sock")
+}
+
+// startLocalServer 启动本地接口，ctx 取消时停止
+// - 只监听工作目录下的 unix socket（权限 0600），不需要 DooTask 身份
+func startLocalServer(ctx context.Context) {
+	socketFile := localSocketFile()
+	_ = os.Remove(socketFile)
+	listener, err := net.Listen("unix", socketFile)
+	if err != nil {
+		fmt.Printf("本地接口启动失败: %v\n", err)
+		return
+	}
+	_ = os.Chmod(socketFile, 0600)
+
+	r := gin.New()
+	r.Use(gin.Recovery(), func(c *gin.Context) {
+		global.Language = global.DefaultLanguage
+		if lang := c.GetHeader("Language"); lang != "" {
+			global.Language = strings.ToLower(lang)
+		}
+		c.Next()
+	})
+	local := r.Group("/api/" + global.APIVersion + "/local")
+	{
+		local.POST("/install", routeLocalInstall)           // 安装应用
+		local.GET("/uninstall/:appId", routeLocalUninstall) // 卸载应用
+		local.GET("/status/:appId", routeLocalStatus)       // 获取应用安装状态
+	}
+
+	server := &http.Server{Handler: r}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("本地接口已停止: %v\n", err)
+	}
+}
+
+// routeLocalInstall 安装应用，返回安装的版本
+func routeLocalInstall(c *gin.Context) {
+	var req models.AppInternalInstallRequest
+	if err := response.CheckBindAndValidate(&req, c); err != nil {
+		return
+	}
+	version, stderr, err := models.InstallApp(&req)
+	if version == "" {
+		response.ErrorWithDetail(c, global.CodeError, stderr, err)
+		return
+	}
+	response.SuccessWithData(c, gin.H{"version": version})
+}
+
+// routeLocalUninstall 卸载应用，返回卸载的版本
+func routeLocalUninstall(c *gin.Context) {
+	version, stderr, err := models.UninstallApp(c.Param("appId"), c.Query("force") == "true")
+	if version == "" {
+		response.ErrorWithDetail(c, global.CodeError, stderr, err)
+		return
+	}
+	response.SuccessWithData(c, gin.H{"version": version})
+}
+
+// routeLocalStatus 获取应用安装状态（正在安装依赖时为 installing）
+func routeLocalStatus(c *gin.Context) {
+	response.SuccessWithData(c, gin.H{"status": models.CurrentAppStatus(c.Param("appId"))})
+}
+
+// localClient 连接运行中服务的本地接口，服务未运行时返回 nil
+func localClient() *http.Client {
+	socketFile := localSocketFile()
+	conn, err := net.DialTimeout("unix", socketFile, time.Second)
+	if err != nil {
+		return nil
+	}
+	conn.Close()
+	return &http.Client{
+		Timeout: time.Minute,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketFile)
+			},
+		},
+	}
+}
+
+// localRequest 调用本地接口，返回响应数据中的字段（失败时输出错误并退出）
+func localRequest(client *http.Client, method, path string, body interface{}) map[string]string {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, "http://appstore/api/"+global.APIVersion+"/local"+path, reader)
+	if err != nil {
+		exitWithError("请求运行中的服务失败", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Language", cliLanguage)
+	resp, err := client.Do(req)
+	if err != nil {
+		exitWithError("请求运行中的服务失败", err)
+	}
+	defer resp.Body.Close()
+
+	result := struct {
+		Code    int               `json:"code"`
+		Message string            `json:"message"`
+		Data    map[string]string `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		exitWithError("请求运行中的服务失败", err)
+	}
+	if result.Code != global.CodeSuccess {
+		var detail error
+		if result.Data["error"] != "" {
+			detail = errors.New(result.Data["error"])
+		}
+		exitWithError(result.Message, detail)
+	}
+	return result.Data
+}
+
+// localWaitFinished 等待运行中的服务完成安装或卸载，返回最终状态，超时返回当前状态
+func localWaitFinished(client *http.Client, appId string, timeout time.Duration) string {
+	deadline := time.Now().Add(timeout)
+	for {
+		status := localRequest(client, "GET", "/status/"+appId, nil)["status"]
+		if (status != "installing" && status != "uninstalling") || time.Now().After(deadline) {
+			return status
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// lockWorkDir 命令行在当前进程中安装或卸载前获取工作目录锁（失败时输出错误并退出），返回释放锁的函数
+// - 收到 SIGINT、SIGTERM 时将正在安装或卸载的应用（包括先安装的依赖）标记为错误后退出
+func lockWorkDir() func() {
+	release, err := models.LockWorkDir(false)
+	if errors.Is(err, models.ErrWorkDirLocked) {
+		exitWithError("另一个进程正在安装或卸载应用，请稍后再试", nil)
+	}
+	if err != nil {
+		exitWithError("获取工作目录锁失败", err)
+	}
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		models.MarkInterruptedApps()
+		fmt.Fprintln(os.Stderr, i18n.T("AppOperationInterrupted"))
+		os.Exit(130)
+	}()
+	return release
+}
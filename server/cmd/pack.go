@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"appstore/server/models"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	newOptions models.ScaffoldOptions
+	newCmd     = &cobra.Command{
+		Use:   "new <应用目录>",
+		Short: "生成应用骨架",
+		Long:  "在指定目录生成应用骨架：config.yml、多语言 README、logo.svg 以及初始版本的 docker-compose.yml 和 nginx.conf",
+		Args:  cobra.ExactArgs(1),
+		Run:   runNew,
+	}
+
+	packVersion string
+	packOutput  string
+	packFormat  string
+	packCmd     = &cobra.Command{
+		Use:   "pack <应用目录>",
+		Short: "检查并打包应用",
+		Long:  "检查应用目录（同 lint），生成可复现的 tar.gz 应用包和 .sha256 摘要文件，可通过上传接口安装（上传时传入 digest 校验）。只生成摘要，不支持签名：摘要只能发现传输中的损坏或篡改，不能证明发布者身份，需要通过可信渠道获取摘要",
+		Args:  cobra.ExactArgs(1),
+		Run:   runPack,
+	}
+)
+
+func init() {
+	newCmd.Flags().StringVar(&newOptions.AppID, "id", "", "应用ID（默认为目录名）")
+	newCmd.Flags().StringVar(&newOptions.Name, "name", "", "应用名称（默认为应用ID）")
+	newCmd.Flags().StringVar(&newOptions.Author, "author", "", "作者")
+	newCmd.Flags().StringVar(&newOptions.Version, "version", "1.0.0", "初始版本")
+	newCmd.Flags().StringVar(&newOptions.Image, "image", "nginx:alpine", "容器镜像")
+	newCmd.Flags().IntVar(&newOptions.Port, "port", 80, "容器内服务端口")
+	rootCmd.AddCommand(newCmd)
+
+	packCmd.Flags().StringVar(&packVersion, "version", "", "只打包指定版本（默认打包所有版本）")
+	packCmd.Flags().StringVarP(&packOutput, "output", "o", ".", "应用包输出目录")
+	packCmd.Flags().StringVar(&packFormat, "format", "text", "输出格式 (text/json)")
+	rootCmd.AddCommand(packCmd)
+}
+
+func runNew(_ *cobra.Command, args []string) {
+	files, err := models.CreateAppScaffold(args[0], newOptions)
+	if err != nil {
+		fmt.Printf("生成应用骨架失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("已生成应用骨架: %s\n", args[0])
+	for _, file := range files {
+		fmt.Printf("  %s\n", file)
+	}
+	fmt.Printf("修改后使用 appstore lint %s 检查，appstore pack %s 打包\n", args[0], args[0])
+}
+
+func runPack(_ *cobra.Command, args []string) {
+	if packFormat != "text" && packFormat != "json" {
+		fmt.Printf("不支持的输出格式: %s\n", packFormat)
+		os.Exit(1)
+	}
+
+	result, lint, err := models.PackApp(args[0], packVersion, packOutput)
+	if err != nil {
+		if packFormat == "json" {
+			data, _ := json.MarshalIndent(map[string]interface{}{"error": err.Error(), "lint": lint}, "", "  ")
+			fmt.Println(string(data))
+		} else {
+			fmt.Printf("打包失败: %v\n", err)
+			for _, issue := range lint.Issues {
+				file := issue.File
+				if file == "" {
+					file = "-"
+				}
+				fmt.Printf("  %-8s %-24s %-28s %s\n", issue.Level, file, issue.Code, issue.Message)
+			}
+		}
+		os.Exit(1)
+	}
+
+	if packFormat == "json" {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("应用包: %s (%d bytes)\n", result.File, result.Size)
+	fmt.Printf("文件摘要: %s (%s)\n", result.SHA256, result.ChecksumFile)
+	fmt.Printf("内容摘要: %s\n", result.Digest)
+	if lint.Warnings > 0 {
+		fmt.Printf("检查警告: %d 个（使用 appstore lint %s 查看）\n", lint.Warnings, args[0])
+	}
+}
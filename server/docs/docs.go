@@ -75,7 +75,7 @@ const docTemplate = `{
         },
         "/internal/apps/update": {
             "get": {
-                "description": "从远程仓库更新应用列表，给 DooTask 内部应用商店使用",
+                "description": "从远程仓库增量更新应用列表（只下载摘要变化的应用，远程不支持仓库索引时下载完整资源包），给 DooTask 内部应用商店使用，返回每个应用的差异（new、unchanged、updated、modified）",
                 "consumes": [
                     "application/json"
                 ],
@@ -86,11 +86,72 @@ const docTemplate = `{
                     "内部接口"
                 ],
                 "summary": "更新应用列表",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "试运行，只返回差异不更新",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "需要覆盖的本地修改、来源未知或非仓库来源的应用ID，多个用逗号分隔，* 表示全部",
+                        "name": "overwrite",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/response.Response"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.SourcesSyncResult"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/internal/apps/update/status": {
+            "get": {
+                "description": "获取后台定时更新应用列表的配置、最近的更新记录以及新增的可升级应用",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "内部接口"
+                ],
+                "summary": "后台更新应用列表状态",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.SourcesUpdateStatus"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 }
@@ -122,6 +183,12 @@ const docTemplate = `{
                         "description": "应用ID，留空则从文件名自动提取",
                         "name": "appid",
                         "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "应用包的sha256摘要（sha256:xxx），不为空时校验上传的文件",
+                        "name": "digest",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
@@ -185,7 +252,7 @@ const docTemplate = `{
         },
         "/internal/installed": {
             "get": {
-                "description": "获取所有已安装的应用列表",
+                "description": "获取所有已安装的应用列表，包含各服务的运行状态（容器状态、健康、重启次数、运行时长、镜像及摘要，缓存5秒）",
                 "consumes": [
                     "application/json"
                 ],
@@ -277,9 +344,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/internal/uninstall/{appId}": {
+        "/internal/runtime/{appId}": {
             "get": {
-                "description": "卸载指定的应用",
+                "description": "获取指定应用各服务的运行状态（容器状态、健康、重启次数、运行时长、镜像及摘要，缓存5秒）",
                 "consumes": [
                     "application/json"
                 ],
@@ -289,7 +356,7 @@ const docTemplate = `{
                 "tags": [
                     "内部接口"
                 ],
-                "summary": "卸载应用",
+                "summary": "获取应用运行状态",
                 "parameters": [
                     {
                         "type": "string",
@@ -303,15 +370,27 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/response.Response"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.AppRuntime"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 }
             }
         },
-        "/list": {
+        "/internal/uninstall/{appId}": {
             "get": {
-                "description": "获取所有可用的应用列表",
+                "description": "卸载指定的应用（已安装或安装失败的应用），有其他已安装应用依赖此应用时需要 force=true 才能卸载",
                 "consumes": [
                     "application/json"
                 ],
@@ -319,17 +398,58 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "应用"
+                    "内部接口"
                 ],
-                "summary": "获取应用列表",
+                "summary": "卸载应用",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "应用ID列表，多个应用ID用逗号分隔",
-                        "name": "appIds",
+                        "description": "应用ID",
+                        "name": "appId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "有其他应用依赖或卸载前钩子失败时是否强制卸载",
+                        "name": "force",
                         "in": "query"
                     }
                 ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/internal/upgrade/channel": {
+            "post": {
+                "description": "设置应用订阅的发布通道（stable、beta、dev），应用详情、最新版本和升级只考虑通道内的版本",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "内部接口"
+                ],
+                "summary": "设置发布通道",
+                "parameters": [
+                    {
+                        "description": "发布通道参数",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AppInternalChannelRequest"
+                        }
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -342,10 +462,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "array",
-                                            "items": {
-                                                "$ref": "#/definitions/models.App"
-                                            }
+                                            "$ref": "#/definitions/models.AppConfig"
                                         }
                                     }
                                 }
@@ -355,9 +472,55 @@ const docTemplate = `{
                 }
             }
         },
-        "/one/{appId}": {
+        "/internal/upgrade/pin": {
+            "post": {
+                "description": "固定已安装应用的版本（不提示升级，也不自动升级），或设置允许升级的版本范围（例如 ~1.2、^2.0、\u003e=1.0 \u003c2.0）",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "内部接口"
+                ],
+                "summary": "固定应用版本",
+                "parameters": [
+                    {
+                        "description": "固定版本参数",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AppInternalPinRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.AppConfig"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/internal/upgrade/plan/{appId}": {
             "get": {
-                "description": "获取指定应用的详细信息",
+                "description": "获取已安装应用的升级计划：候选目标版本、需要先卸载的要求、新增和已移除的字段、各版本更新日志",
                 "consumes": [
                     "application/json"
                 ],
@@ -365,9 +528,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "应用"
+                    "内部接口"
                 ],
-                "summary": "获取应用详情",
+                "summary": "获取升级计划",
                 "parameters": [
                     {
                         "type": "string",
@@ -389,7 +552,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/models.App"
+                                            "$ref": "#/definitions/models.UpgradePlan"
                                         }
                                     }
                                 }
@@ -399,9 +562,55 @@ const docTemplate = `{
                 }
             }
         },
-        "/readme/{appId}": {
+        "/internal/upgrade/policy": {
+            "post": {
+                "description": "设置已安装应用的自动升级策略（manual、notify、auto-patch、auto-minor、auto-all）和维护时间段，更新应用列表后按策略自动升级",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "内部接口"
+                ],
+                "summary": "设置自动升级策略",
+                "parameters": [
+                    {
+                        "description": "自动升级策略",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AppInternalUpgradePolicyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.AppConfig"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/list": {
             "get": {
-                "description": "获取指定应用的README文件内容",
+                "description": "获取所有可用的应用列表",
                 "consumes": [
                     "application/json"
                 ],
@@ -411,14 +620,13 @@ const docTemplate = `{
                 "tags": [
                     "应用"
                 ],
-                "summary": "获取应用自述文件",
+                "summary": "获取应用列表",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "应用ID",
-                        "name": "appId",
-                        "in": "path",
-                        "required": true
+                        "description": "应用ID列表，多个应用ID用逗号分隔",
+                        "name": "appIds",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -433,9 +641,9 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "type": "object",
-                                            "additionalProperties": {
-                                                "type": "string"
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.App"
                                             }
                                         }
                                     }
@@ -446,28 +654,191 @@ const docTemplate = `{
                 }
             }
         },
-        "/sources/package": {
+        "/one/{appId}": {
             "get": {
-                "description": "获取应用商店源列表压缩包",
+                "description": "获取指定应用的详细信息（包含各版本更新日志，已安装时包含各服务的运行状态和健康，容器、镜像等详细信息见 /internal/runtime/{appId}）",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/gzip"
+                    "application/json"
                 ],
                 "tags": [
-                    "资源"
+                    "应用"
+                ],
+                "summary": "获取应用详情",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "应用ID",
+                        "name": "appId",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "应用商店源列表",
                 "responses": {
                     "200": {
-                        "description": "sources.tar.gz",
+                        "description": "OK",
                         "schema": {
-                            "type": "file"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.App"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 }
             }
+        },
+        "/readme/{appId}": {
+            "get": {
+                "description": "获取指定应用的README文件内容、指定版本（默认最新版本）的更新日志，以及两个版本之间（默认从已安装版本到最新版本）的更新日志",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "应用"
+                ],
+                "summary": "获取应用自述文件",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "应用ID",
+                        "name": "appId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "版本号，默认最新版本",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "起始版本（不含），默认已安装的版本",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "目标版本（含），默认最新版本",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/response.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "object",
+                                            "additionalProperties": true
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/schema/config.json": {
+            "get": {
+                "description": "获取应用配置文件（config.yml）的 JSON Schema，可用于编辑器校验和自动补全",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "应用"
+                ],
+                "summary": "获取应用配置文件的 JSON Schema",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/sources/index": {
+            "get": {
+                "description": "获取应用商店仓库索引（每个应用的最新版本、各版本摘要、大小、更新时间），支持 If-None-Match 条件请求，应用包变化前使用缓存",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "资源"
+                ],
+                "summary": "应用商店仓库索引",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SourcesIndex"
+                        }
+                    },
+                    "304": {
+                        "description": "索引未变化"
+                    }
+                }
+            }
+        },
+        "/sources/package": {
+            "get": {
+                "description": "获取应用商店源列表压缩包，按 apps 目录内容摘要缓存，支持 ETag、Last-Modified 条件请求与 Range 断点续传",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/gzip"
+                ],
+                "tags": [
+                    "资源"
+                ],
+                "summary": "应用商店源列表",
+                "responses": {
+                    "200": {
+                        "description": "sources.tar.gz",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "sources.tar.gz",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "资源包未变化"
+                    }
+                }
+            }
         }
     },
     "definitions": {
@@ -477,13 +848,38 @@ const docTemplate = `{
                 "author": {
                     "type": "string"
                 },
+                "blue_green": {
+                    "description": "使用蓝绿升级的版本范围",
+                    "type": "string"
+                },
+                "changelogs": {
+                    "description": "各版本更新日志（只在应用详情中返回）",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.VersionChangelog"
+                    }
+                },
+                "compatible": {
+                    "description": "是否有兼容当前 DooTask 版本和平台的版本",
+                    "type": "boolean"
+                },
                 "config": {
                     "$ref": "#/definitions/models.AppConfig"
                 },
+                "conflicts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AppConflict"
+                    }
+                },
                 "description": {},
                 "document": {
                     "type": "string"
                 },
+                "dootask_version": {
+                    "description": "要求的 DooTask 版本范围",
+                    "type": "string"
+                },
                 "download_url": {
                     "type": "string"
                 },
@@ -499,12 +895,34 @@ const docTemplate = `{
                 "github": {
                     "type": "string"
                 },
+                "health_checks": {
+                    "description": "健康检查",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.HealthCheck"
+                    }
+                },
+                "host": {
+                    "description": "子域名路由的访问域名",
+                    "type": "string"
+                },
                 "icon": {
                     "type": "string"
                 },
                 "id": {
                     "type": "string"
                 },
+                "incompatible_reason": {
+                    "description": "不兼容的原因",
+                    "type": "string"
+                },
+                "incompatible_versions": {
+                    "description": "不兼容的版本及原因",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
                 "menu_items": {
                     "type": "array",
                     "items": {
@@ -512,6 +930,13 @@ const docTemplate = `{
                     }
                 },
                 "name": {},
+                "platforms": {
+                    "description": "支持的平台，为空表示所有平台",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "rating": {
                     "type": "number"
                 },
@@ -521,6 +946,27 @@ const docTemplate = `{
                         "$ref": "#/definitions/models.RequireUninstall"
                     }
                 },
+                "requires": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AppRequire"
+                    }
+                },
+                "routing": {
+                    "description": "路由模式（path/subdomain，默认 path）",
+                    "type": "string"
+                },
+                "runtime": {
+                    "description": "各服务的运行状态（只在应用详情中返回，只包含状态和健康）",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.AppRuntimeState"
+                        }
+                    ]
+                },
+                "source": {
+                    "$ref": "#/definitions/models.AppSource"
+                },
                 "tags": {
                     "type": "array",
                     "items": {
@@ -547,6 +993,18 @@ const docTemplate = `{
         "models.AppConfig": {
             "type": "object",
             "properties": {
+                "channel": {
+                    "description": "订阅的发布通道：stable, beta, dev",
+                    "type": "string"
+                },
+                "degraded": {
+                    "description": "容器反复崩溃的原因，不为空时容器守护停止自动重启（重新安装或容器恢复正常后清除）",
+                    "type": "string"
+                },
+                "error": {
+                    "description": "最近一次安装失败的原因（例如nginx配置测试失败）",
+                    "type": "string"
+                },
                 "install_at": {
                     "type": "string"
                 },
@@ -560,12 +1018,32 @@ const docTemplate = `{
                     "type": "object",
                     "additionalProperties": true
                 },
+                "pinned": {
+                    "description": "固定当前版本，不提示升级也不自动升级",
+                    "type": "boolean"
+                },
+                "project": {
+                    "description": "compose项目名称（蓝绿升级后交替使用，为空表示 dootask-app-\u003c应用ID\u003e）",
+                    "type": "string"
+                },
                 "resources": {
                     "$ref": "#/definitions/models.AppConfigResources"
                 },
                 "status": {
                     "description": "installing, installed, uninstalling, not_installed, error",
                     "type": "string"
+                },
+                "upgrade_policy": {
+                    "description": "manual, notify, auto-patch, auto-minor, auto-all",
+                    "type": "string"
+                },
+                "upgrade_window": {
+                    "description": "自动升级时间段，例如 02:00-05:00",
+                    "type": "string"
+                },
+                "version_range": {
+                    "description": "允许升级的版本范围，例如 ~1.2",
+                    "type": "string"
                 }
             }
         },
@@ -580,6 +1058,42 @@ const docTemplate = `{
                 }
             }
         },
+        "models.AppConflict": {
+            "type": "object",
+            "properties": {
+                "app": {
+                    "description": "冲突的应用ID",
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "冲突原因（支持多语言）"
+                },
+                "version": {
+                    "description": "冲突的版本范围，例如 \u003c2.0，为空表示所有版本",
+                    "type": "string"
+                }
+            }
+        },
+        "models.AppInternalChannelRequest": {
+            "type": "object",
+            "required": [
+                "appid",
+                "channel"
+            ],
+            "properties": {
+                "appid": {
+                    "type": "string"
+                },
+                "channel": {
+                    "type": "string",
+                    "enum": [
+                        "stable",
+                        "beta",
+                        "dev"
+                    ]
+                }
+            }
+        },
         "models.AppInternalDownloadRequest": {
             "type": "object",
             "required": [
@@ -603,6 +1117,10 @@ const docTemplate = `{
                 "appid": {
                     "type": "string"
                 },
+                "install_requires": {
+                    "description": "是否先安装缺少的依赖应用（按依赖顺序依次安装）",
+                    "type": "boolean"
+                },
                 "params": {
                     "type": "object",
                     "additionalProperties": true
@@ -626,72 +1144,641 @@ const docTemplate = `{
                     "items": {
                         "$ref": "#/definitions/models.MenuItem"
                     }
+                },
+                "runtime": {
+                    "description": "运行状态",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.AppRuntime"
+                        }
+                    ]
                 }
             }
         },
-        "models.FieldConfig": {
+        "models.AppInternalPinRequest": {
             "type": "object",
+            "required": [
+                "appid"
+            ],
             "properties": {
-                "default": {},
-                "label": {},
-                "name": {
+                "appid": {
                     "type": "string"
                 },
-                "options": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.FieldOption"
-                    }
-                },
-                "placeholder": {},
-                "required": {
+                "pinned": {
                     "type": "boolean"
                 },
-                "type": {
+                "version_range": {
+                    "description": "允许升级的版本范围，例如 ~1.2",
                     "type": "string"
                 }
             }
         },
-        "models.FieldOption": {
+        "models.AppInternalUpgradePolicyRequest": {
             "type": "object",
+            "required": [
+                "appid",
+                "policy"
+            ],
             "properties": {
-                "label": {},
-                "value": {
+                "appid": {
+                    "type": "string"
+                },
+                "policy": {
+                    "type": "string",
+                    "enum": [
+                        "manual",
+                        "notify",
+                        "auto-patch",
+                        "auto-minor",
+                        "auto-all"
+                    ]
+                },
+                "window": {
                     "type": "string"
                 }
             }
         },
-        "models.MenuItem": {
+        "models.AppRequire": {
             "type": "object",
             "properties": {
-                "autoDarkTheme": {
-                    "type": "boolean"
-                },
-                "icon": {
+                "app": {
+                    "description": "依赖的应用ID",
                     "type": "string"
                 },
-                "keepAlive": {
+                "optional": {
+                    "description": "可选依赖：未安装时不影响安装，已安装时需满足版本范围",
                     "type": "boolean"
                 },
-                "label": {},
-                "location": {
+                "version": {
+                    "description": "允许的版本范围，例如 ^1.0，为空表示任意版本",
+                    "type": "string"
+                }
+            }
+        },
+        "models.AppRuntime": {
+            "type": "object",
+            "properties": {
+                "degraded": {
+                    "description": "容器反复崩溃的原因（容器守护已停止自动重启）",
                     "type": "string"
                 },
-                "transparent": {
-                    "type": "boolean"
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ServiceRuntime"
+                    }
                 },
-                "url": {
+                "status": {
+                    "description": "healthy、unhealthy、stopped、unknown",
+                    "type": "string"
+                },
+                "updated_at": {
                     "type": "string"
                 }
             }
         },
-        "models.RequireUninstall": {
+        "models.AppRuntimeState": {
             "type": "object",
             "properties": {
-                "operator": {
-                    "type": "string"
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ServiceState"
+                    }
+                },
+                "status": {
+                    "description": "healthy、unhealthy、stopped、unknown",
+                    "type": "string"
+                }
+            }
+        },
+        "models.AppSource": {
+            "type": "object",
+            "properties": {
+                "digest": {
+                    "description": "写入apps目录时的内容摘要（不含已安装版本的运行时数据）",
+                    "type": "string"
+                },
+                "filename": {
+                    "description": "上传的文件名",
+                    "type": "string"
+                },
+                "modified": {
+                    "description": "当前内容与记录的摘要不一致（本地被修改）",
+                    "type": "boolean"
+                },
+                "remote_digest": {
+                    "description": "同步时仓库索引中的摘要",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "repo, url, git, upload",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "description": "仓库地址或下载地址",
+                    "type": "string"
+                }
+            }
+        },
+        "models.FieldConfig": {
+            "type": "object",
+            "properties": {
+                "default": {},
+                "label": {},
+                "name": {
+                    "type": "string"
+                },
+                "options": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldOption"
+                    }
+                },
+                "placeholder": {},
+                "required": {
+                    "type": "boolean"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FieldOption": {
+            "type": "object",
+            "properties": {
+                "label": {},
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.HealthCheck": {
+            "type": "object",
+            "properties": {
+                "command": {
+                    "description": "执行的命令（command，通过 sh -c 执行）",
+                    "type": "string"
+                },
+                "path": {
+                    "description": "请求路径（http），默认 /",
+                    "type": "string"
+                },
+                "port": {
+                    "description": "容器内端口（http、tcp）",
+                    "type": "integer"
+                },
+                "service": {
+                    "description": "服务名称",
+                    "type": "string"
+                },
+                "timeout": {
+                    "description": "等待健康的超时时间（秒），取所有检查中的最大值，默认120",
+                    "type": "integer"
+                },
+                "type": {
+                    "description": "http、tcp、command",
+                    "type": "string"
+                }
+            }
+        },
+        "models.MenuItem": {
+            "type": "object",
+            "properties": {
+                "autoDarkTheme": {
+                    "type": "boolean"
+                },
+                "icon": {
+                    "type": "string"
+                },
+                "keepAlive": {
+                    "type": "boolean"
+                },
+                "label": {},
+                "location": {
+                    "type": "string"
+                },
+                "onlyAdmin": {
+                    "type": "boolean"
+                },
+                "transparent": {
+                    "type": "boolean"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.RequireUninstall": {
+            "type": "object",
+            "properties": {
+                "operator": {
+                    "type": "string"
+                },
+                "reason": {},
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ServiceRuntime": {
+            "type": "object",
+            "properties": {
+                "container": {
+                    "description": "容器名称，容器不存在时为空",
+                    "type": "string"
+                },
+                "digest": {
+                    "description": "镜像摘要，例如 sha256:...（本地构建的镜像为镜像ID）",
+                    "type": "string"
+                },
+                "exit_code": {
+                    "description": "最近一次退出码",
+                    "type": "integer"
+                },
+                "health": {
+                    "description": "healthy、unhealthy、starting，未配置 healthcheck 时为空",
+                    "type": "string"
+                },
+                "image": {
+                    "description": "镜像，例如 nginx:1.25",
+                    "type": "string"
+                },
+                "restart_count": {
+                    "description": "Docker 重启容器的次数",
+                    "type": "integer"
+                },
+                "service": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "description": "最近一次启动时间",
+                    "type": "string"
+                },
+                "state": {
+                    "description": "running、exited、restarting、paused、created，容器不存在时为 missing",
+                    "type": "string"
+                },
+                "uptime": {
+                    "description": "运行时长（秒），未运行时为0",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ServiceState": {
+            "type": "object",
+            "properties": {
+                "health": {
+                    "description": "healthy、unhealthy、starting，未配置 healthcheck 时为空",
+                    "type": "string"
+                },
+                "service": {
+                    "type": "string"
+                },
+                "state": {
+                    "description": "running、exited、restarting、paused、created，容器不存在时为 missing",
+                    "type": "string"
+                }
+            }
+        },
+        "models.SourcesDiffItem": {
+            "type": "object",
+            "properties": {
+                "added_versions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "changed_versions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "local_version": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "remote_version": {
+                    "type": "string"
+                },
+                "removed_versions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "result": {
+                    "description": "success, failed, skipped, unchanged（试运行时为空）",
+                    "type": "string"
+                },
+                "source": {
+                    "description": "本地应用包来源：repo, url, git, upload（未记录时为空）",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "new, unchanged, updated, modified, foreign",
+                    "type": "string"
+                }
+            }
+        },
+        "models.SourcesIndex": {
+            "type": "object",
+            "properties": {
+                "apps": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.SourcesIndexApp"
+                    }
+                },
+                "format": {
+                    "type": "integer"
+                },
+                "generated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SourcesIndexApp": {
+            "type": "object",
+            "properties": {
+                "digest": {
+                    "type": "string"
+                },
+                "latest_version": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "versions": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.SourcesIndexVersion"
+                    }
+                }
+            }
+        },
+        "models.SourcesIndexVersion": {
+            "type": "object",
+            "properties": {
+                "digest": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SourcesSyncResult": {
+            "type": "object",
+            "properties": {
+                "apps": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SourcesDiffItem"
+                    }
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "failed": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "skipped": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "success": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "unchanged": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "upgrades": {
+                    "description": "同步后自动升级策略的评估结果",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UpgradeDecision"
+                    }
+                }
+            }
+        },
+        "models.SourcesUpdateRecord": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "new_upgradeable": {
+                    "description": "本次更新后新增的可升级应用",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UpgradeableApp"
+                    }
+                },
+                "result": {
+                    "$ref": "#/definitions/models.SourcesSyncResult"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "upgradeable": {
+                    "description": "当前可升级的已安装应用",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UpgradeableApp"
+                    }
+                }
+            }
+        },
+        "models.SourcesUpdateStatus": {
+            "type": "object",
+            "properties": {
+                "interval": {
+                    "description": "更新间隔，0 表示不自动更新",
+                    "type": "string"
+                },
+                "records": {
+                    "description": "最近的更新记录（新的在前）",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SourcesUpdateRecord"
+                    }
+                },
+                "window": {
+                    "description": "允许更新的时间段，为空表示不限制",
+                    "type": "string"
+                }
+            }
+        },
+        "models.UpgradeCandidate": {
+            "type": "object",
+            "properties": {
+                "allowed": {
+                    "description": "是否在允许升级的版本范围内（固定版本时为 false）",
+                    "type": "boolean"
+                },
+                "blocked": {
+                    "description": "是否需要先卸载",
+                    "type": "boolean"
+                },
+                "blocked_by": {
+                    "description": "命中的“需要先卸载”要求",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.RequireUninstall"
+                    }
+                },
+                "changelogs": {
+                    "description": "从已安装版本到该版本之间的更新日志（从新到旧）",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.VersionChangelog"
+                    }
+                },
+                "channel": {
+                    "type": "string"
+                },
+                "incompatible": {
+                    "description": "不兼容当前 DooTask 版本或平台的原因",
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UpgradeDecision": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "notify, queued, waiting, blocked",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "installed_version": {
+                    "type": "string"
+                },
+                "latest_version": {
+                    "type": "string"
+                },
+                "policy": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "target_version": {
+                    "description": "按策略可以自动升级到的版本",
+                    "type": "string"
+                }
+            }
+        },
+        "models.UpgradePlan": {
+            "type": "object",
+            "properties": {
+                "candidates": {
+                    "description": "可升级的目标版本（从新到旧）",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UpgradeCandidate"
+                    }
+                },
+                "channel": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "installed_version": {
+                    "type": "string"
+                },
+                "new_fields": {
+                    "description": "新增的字段，升级时需要填写",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldConfig"
+                    }
+                },
+                "pinned": {
+                    "type": "boolean"
+                },
+                "removed_fields": {
+                    "description": "已移除的字段，升级后不再使用",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "version_range": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UpgradeableApp": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "installed_version": {
+                    "type": "string"
+                },
+                "latest_version": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.VersionChangelog": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
                 },
-                "reason": {},
                 "version": {
                     "type": "string"
                 }
@@ -1,6 +1,10 @@
 package global
 
-import "github.com/go-playground/validator/v10"
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
 
 // 常量
 const (
@@ -23,6 +27,13 @@ var (
 	EnvFile     string // 环境变量文件，需要加载的环境变量文件
 	WebDir      string // 前端静态文件目录，用于存储前端静态文件
 
+	UpdateInterval time.Duration // 后台自动更新应用列表的间隔，0 表示不自动更新
+	UpdateWindow   string        // 允许后台自动更新的时间段，例如 02:00-05:00
+
+	AppDomain  string // 子域名路由的基础域名，例如 apps.example.com（应用访问地址为 <应用ID>.apps.example.com）
+	AppTLSCert string // 子域名路由的证书路径（nginx容器内的路径），为空时只监听80端口
+	AppTLSKey  string // 子域名路由的证书私钥路径（nginx容器内的路径）
+
 	BaseUrl  string // 基础URL
 	Port     string // 服务端口
 	Language string // 用户语言
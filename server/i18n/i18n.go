@@ -19,6 +19,9 @@ var (
 	initOnce  sync.Once
 
 	placeholderRegex = regexp.MustCompile(`%[sdv]`)
+
+	// languageAliases 不是有效语言标签的语言名称（小写）对应的语言标签，例如翻译文件 zh-CHT.yaml
+	languageAliases = map[string]string{"zh-cht": "zh-Hant"}
 )
 
 // T 获取翻译文本
@@ -54,7 +57,20 @@ func T(messageID string, args ...interface{}) string {
 	if localizer == nil {
 		return messageID
 	}
+	return localize(localizer, messageID, args...)
+}
 
+// TL 获取指定语言的翻译文本（不影响当前语言），参数同 T
+func TL(lang string, messageID string, args ...interface{}) string {
+	initI18n()
+	if bundle == nil {
+		return messageID
+	}
+	return localize(i18n.NewLocalizer(bundle, languageTags(lang, global.DefaultLanguage)...), messageID, args...)
+}
+
+// localize 使用指定的本地化器翻译文本
+func localize(localizer *i18n.Localizer, messageID string, args ...interface{}) string {
 	// 创建本地化配置
 	config := &i18n.LocalizeConfig{
 		MessageID: messageID,
@@ -123,7 +139,19 @@ func T(messageID string, args ...interface{}) string {
 func UpdateLocalizer(lang ...string) {
 	initI18n()
 	lang = append(lang, global.Language, global.DefaultLanguage)
-	localizer = i18n.NewLocalizer(bundle, lang...)
+	localizer = i18n.NewLocalizer(bundle, languageTags(lang...)...)
+}
+
+// languageTags 将语言名称转换为语言标签（例如 zh-CHT => zh-Hant）
+func languageTags(langs ...string) []string {
+	tags := make([]string, 0, len(langs))
+	for _, lang := range langs {
+		if tag, ok := languageAliases[strings.ToLower(lang)]; ok {
+			lang = tag
+		}
+		tags = append(tags, lang)
+	}
+	return tags
 }
 
 // ****************************************************************************
@@ -144,11 +172,20 @@ func initI18n() {
 		entries, _ := LocaleFS.ReadDir("locales")
 		for _, entry := range entries {
 			if strings.HasSuffix(entry.Name(), ".yaml") {
-				_, _ = bundle.LoadMessageFileFS(LocaleFS, "locales/"+entry.Name())
+				data, err := LocaleFS.ReadFile("locales/" + entry.Name())
+				if err != nil {
+					continue
+				}
+				// 文件名作为语言标签，不是有效语言标签的文件名使用别名
+				name := strings.TrimSuffix(entry.Name(), ".yaml")
+				if tag, ok := languageAliases[strings.ToLower(name)]; ok {
+					name = tag
+				}
+				_, _ = bundle.ParseMessageFileBytes(data, "locales/"+name+".yaml")
 			}
 		}
 
 		// 创建默认本地化器
-		localizer = i18n.NewLocalizer(bundle, global.Language, global.DefaultLanguage)
+		localizer = i18n.NewLocalizer(bundle, languageTags(global.Language, global.DefaultLanguage)...)
 	})
 }
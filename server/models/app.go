@@ -24,25 +24,41 @@ import (
 
 // App 应用信息结构
 type App struct {
-	ID                string             `yaml:"id" json:"id"`
-	Name              interface{}        `yaml:"name" json:"name"`
-	Description       interface{}        `yaml:"description" json:"description"`
-	Icon              string             `yaml:"icon" json:"icon"`
-	Versions          []string           `yaml:"versions" json:"versions"`
-	Tags              []string           `yaml:"tags" json:"tags"`
-	Author            string             `yaml:"author" json:"author"`
-	Website           string             `yaml:"website" json:"website"`
-	Github            string             `yaml:"github" json:"github"`
-	Document          string             `yaml:"document" json:"document"`
-	DownloadURL       string             `yaml:"download_url" json:"download_url"`
-	Fields            []FieldConfig      `yaml:"fields" json:"fields"`
-	RequireUninstalls []RequireUninstall `yaml:"require_uninstalls" json:"require_uninstalls"`
-	MenuItems         []MenuItem         `yaml:"menu_items" json:"menu_items"`
-	Config            *AppConfig         `yaml:"config,omitempty" json:"config,omitempty"`
-	Rating            float64            `yaml:"rating,omitempty" json:"rating"`
-	UserCount         string             `yaml:"user_count,omitempty" json:"user_count"`
-	Downloads         string             `yaml:"downloads,omitempty" json:"downloads"`
-	Upgradeable       bool               `yaml:"upgradeable,omitempty" json:"upgradeable"`
+	ID                   string                          `yaml:"id" json:"id"`
+	Name                 interface{}                     `yaml:"name" json:"name"`
+	Description          interface{}                     `yaml:"description" json:"description"`
+	Icon                 string                          `yaml:"icon" json:"icon"`
+	Versions             []string                        `yaml:"versions" json:"versions"`
+	Tags                 []string                        `yaml:"tags" json:"tags"`
+	Author               string                          `yaml:"author" json:"author"`
+	Website              string                          `yaml:"website" json:"website"`
+	Github               string                          `yaml:"github" json:"github"`
+	Document             string                          `yaml:"document" json:"document"`
+	DownloadURL          string                          `yaml:"download_url" json:"download_url"`
+	Fields               []FieldConfig                   `yaml:"fields" json:"fields"`
+	RequireUninstalls    []RequireUninstall              `yaml:"require_uninstalls" json:"require_uninstalls"`
+	Requires             []AppRequire                    `yaml:"requires" json:"requires"`
+	Conflicts            []AppConflict                   `yaml:"conflicts" json:"conflicts"`
+	DooTaskVersion       string                          `yaml:"dootask_version" json:"dootask_version"` // 要求的 DooTask 版本范围
+	Platforms            []string                        `yaml:"platforms" json:"platforms"`             // 支持的平台，为空表示所有平台
+	Compatibility        map[string]VersionCompatibility `yaml:"compatibility" json:"-"`                 // 各版本的兼容性要求（覆盖应用配置）
+	MenuItems            []MenuItem                      `yaml:"menu_items" json:"menu_items"`
+	Routing              string                          `yaml:"routing" json:"routing"`                       // 路由模式（path/subdomain，默认 path）
+	Host                 string                          `yaml:"-" json:"host,omitempty"`                      // 子域名路由的访问域名
+	BlueGreen            string                          `yaml:"blue_green" json:"blue_green,omitempty"`       // 使用蓝绿升级的版本范围
+	HealthChecks         []HealthCheck                   `yaml:"health_checks" json:"health_checks,omitempty"` // 健康检查
+	Config               *AppConfig                      `yaml:"config,omitempty" json:"config,omitempty"`
+	Rating               float64                         `yaml:"rating,omitempty" json:"rating"`
+	UserCount            string                          `yaml:"user_count,omitempty" json:"user_count"`
+	Downloads            string                          `yaml:"downloads,omitempty" json:"downloads"`
+	Upgradeable          bool                            `yaml:"upgradeable,omitempty" json:"upgradeable"`
+	Source               *AppSource                      `yaml:"-" json:"source,omitempty"`
+	ChangelogBlock       map[string]interface{}          `yaml:"changelog" json:"-"`                       // 各版本更新日志（版本目录没有 CHANGELOG.md 时使用）
+	Changelogs           []VersionChangelog              `yaml:"-" json:"changelogs,omitempty"`            // 各版本更新日志（只在应用详情中返回）
+	Compatible           bool                            `yaml:"-" json:"compatible"`                      // 是否有兼容当前 DooTask 版本和平台的版本
+	IncompatibleReason   string                          `yaml:"-" json:"incompatible_reason,omitempty"`   // 不兼容的原因
+	IncompatibleVersions map[string]string               `yaml:"-" json:"incompatible_versions,omitempty"` // 不兼容的版本及原因
+	Runtime              *AppRuntimeState                `yaml:"-" json:"runtime,omitempty"`               // 各服务的运行状态（只在应用详情中返回，只包含状态和健康）
 }
 
 // FieldConfig 定义应用的可配置字段结构
@@ -75,6 +91,7 @@ type MenuItem struct {
 	Label         interface{} `yaml:"label" json:"label"`
 	URL           string      `yaml:"url" json:"url"`
 	Icon          string      `yaml:"icon" json:"icon"`
+	OnlyAdmin     bool        `yaml:"onlyAdmin" json:"onlyAdmin"`
 	Transparent   bool        `yaml:"transparent" json:"transparent"`
 	AutoDarkTheme *bool       `yaml:"autoDarkTheme" json:"autoDarkTheme"`
 	KeepAlive     *bool       `yaml:"keepAlive" json:"keepAlive"`
@@ -85,9 +102,46 @@ type AppConfig struct {
 	InstallAt      string                 `yaml:"install_at" json:"install_at"`
 	InstallNum     int                    `yaml:"install_num" json:"install_num"`
 	InstallVersion string                 `yaml:"install_version" json:"install_version"`
-	Status         string                 `yaml:"status" json:"status"` // installing, installed, uninstalling, not_installed, error
+	Status         string                 `yaml:"status" json:"status"`                         // installing, installed, uninstalling, not_installed, error
+	Error          string                 `yaml:"error,omitempty" json:"error,omitempty"`       // 最近一次安装失败的原因（例如nginx配置测试失败）
+	Degraded       string                 `yaml:"degraded,omitempty" json:"degraded,omitempty"` // 容器反复崩溃的原因，不为空时容器守护停止自动重启（重新安装或容器恢复正常后清除）
 	Params         map[string]interface{} `yaml:"params" json:"params"`
 	Resources      AppConfigResources     `yaml:"resources" json:"resources"`
+	UpgradePolicy  string                 `yaml:"upgrade_policy,omitempty" json:"upgrade_policy"` // manual, notify, auto-patch, auto-minor, auto-all
+	UpgradeWindow  string                 `yaml:"upgrade_window,omitempty" json:"upgrade_window"` // 自动升级时间段，例如 02:00-05:00
+	Pinned         bool                   `yaml:"pinned,omitempty" json:"pinned"`                 // 固定当前版本，不提示升级也不自动升级
+	VersionRange   string                 `yaml:"version_range,omitempty" json:"version_range"`   // 允许升级的版本范围，例如 ~1.2
+	Channel        string                 `yaml:"channel,omitempty" json:"channel"`               // 订阅的发布通道：stable, beta, dev
+	Project        string                 `yaml:"project,omitempty" json:"project,omitempty"`     // compose项目名称（蓝绿升级后交替使用，为空表示 dootask-app-<应用ID>）
+}
+
+// AllowedVersions 过滤出允许升级的版本范围内的版本
+func (c *AppConfig) AllowedVersions(versions []string) []string {
+	if c.VersionRange == "" {
+		return versions
+	}
+	allowed := []string{}
+	for _, version := range versions {
+		if utils.CheckVersionConstraint(version, c.VersionRange) {
+			allowed = append(allowed, version)
+		}
+	}
+	return allowed
+}
+
+// ChannelVersions 过滤出订阅的发布通道内的版本
+func (c *AppConfig) ChannelVersions(versions []string) []string {
+	channel := c.Channel
+	if channel == "" {
+		channel = VersionChannelStable
+	}
+	allowed := []string{}
+	for _, version := range versions {
+		if slices.Index(VersionChannels, VersionChannel(version)) <= slices.Index(VersionChannels, channel) {
+			allowed = append(allowed, version)
+		}
+	}
+	return allowed
 }
 
 // AppConfigResources 应用配置资源结构
@@ -102,12 +156,15 @@ type AppInternalInstallRequest struct {
 	Version   string                 `json:"version" validate:"omitempty"`
 	Params    map[string]interface{} `json:"params" validate:"omitempty"`
 	Resources AppConfigResources     `json:"resources" validate:"omitempty"`
+	// 是否先安装缺少的依赖应用（按依赖顺序依次安装）
+	InstallRequires bool `json:"install_requires" validate:"omitempty"`
 }
 
 // AppInternalInstalledResponse 内部安装响应结构
 type AppInternalInstalledResponse struct {
-	ID        string     `yaml:"id" json:"id"`
-	MenuItems []MenuItem `yaml:"menu_items" json:"menu_items"`
+	ID        string      `yaml:"id" json:"id"`
+	MenuItems []MenuItem  `yaml:"menu_items" json:"menu_items"`
+	Runtime   *AppRuntime `yaml:"-" json:"runtime"` // 运行状态
 }
 
 // AppInternalDownloadRequest 通过URL下载应用的请求结构
@@ -174,17 +231,19 @@ func findIcon(appId string) string {
 
 // findVersions 查找应用版本列表
 func findVersions(appId string) []string {
-	appDir := filepath.Join(global.WorkDir, "apps", appId)
+	return findDirVersions(filepath.Join(global.WorkDir, "apps", appId))
+}
 
+// findDirVersions 获取应用目录中的所有版本（按版本号从新到旧排序）
+func findDirVersions(appDir string) []string {
 	versions := []string{}
 	entries, err := utils.GetSubDirs(appDir)
 	if err != nil {
 		return versions
 	}
 
-	versionRegex := regexp.MustCompile(`^v?\d+(\.\d+){1,2}$`)
 	for _, dirName := range entries {
-		if versionRegex.MatchString(dirName) {
+		if utils.IsValidVersion(dirName) {
 			composePath := filepath.Join(appDir, dirName, "docker-compose.yml")
 			if _, err := os.Stat(composePath); err == nil {
 				versions = append(versions, dirName)
@@ -307,14 +366,36 @@ func NewApp(appId string) (*App, error) {
 	downloads := 100000 + rand.Intn(900000)
 	app.Downloads = utils.FormatNumber(downloads) + "+"
 
+	// 子域名路由时菜单地址改写为子域名地址
+	if app.IsSubdomainRouting() {
+		if host, err := AppHost(app.ID); err == nil {
+			app.Host = host
+			for i := range app.MenuItems {
+				app.MenuItems[i].URL = rewriteMenuURL(host, app.MenuItems[i].URL)
+			}
+		}
+	}
+
 	// 获取应用配置
 	app.Config = GetAppConfig(filepath.Join(app.ID))
 
-	// 检查是否可以升级
-	if app.Config != nil && app.Config.InstallVersion != "" && app.Config.Status == "installed" {
+	// 只保留订阅的发布通道内的版本
+	app.Versions = app.Config.ChannelVersions(app.Versions)
+
+	// 获取应用包来源
+	app.Source = GetAppSource(app.ID)
+	if app.Source != nil {
+		app.Source.Modified = checkAppSourceModified(app.ID, app.Source)
+	}
+
+	// 标记不兼容当前 DooTask 版本和平台的版本
+	app.markCompatibility()
+
+	// 检查是否可以升级（固定版本的应用不提示升级，只考虑允许范围内且兼容的版本）
+	if app.Config != nil && app.Config.InstallVersion != "" && app.Config.Status == "installed" && !app.Config.Pinned {
 		currentVersion := app.Config.InstallVersion
-		if len(app.Versions) > 0 {
-			latestVersion := app.Versions[len(app.Versions)-1]
+		if versions := app.CompatibleVersions(app.Config.AllowedVersions(app.Versions)); len(versions) > 0 {
+			latestVersion := versions[0] // 版本按从新到旧排序
 			if utils.CompareVersions(latestVersion, currentVersion) > 0 {
 				app.Upgradeable = true
 			}
@@ -375,6 +456,7 @@ func NewApp(appId string) (*App, error) {
 				Location:    menu.Location,
 				Label:       getLocalizedValue(menu.Label, global.Language),
 				URL:         menu.URL,
+				OnlyAdmin:   menu.OnlyAdmin,   // 默认为 false，直接赋值
 				Transparent: menu.Transparent, // 默认为 false，直接赋值
 			}
 
@@ -427,6 +509,14 @@ func GetAppConfig(appId string) *AppConfig {
 		appConfig.Params = make(map[string]interface{})
 	}
 
+	if appConfig.UpgradePolicy == "" {
+		appConfig.UpgradePolicy = UpgradePolicyManual
+	}
+
+	if appConfig.Channel == "" {
+		appConfig.Channel = VersionChannelStable
+	}
+
 	return appConfig
 }
 
@@ -446,23 +536,31 @@ func SaveAppConfig(appId string, appConfig *AppConfig) error {
 
 // GetReadme 获取应用的自述文件内容
 func GetReadme(appId string) string {
-	// 定义可能的 README 文件名模式
+	return readLocalizedFile(filepath.Join(global.WorkDir, "apps", appId), "README")
+}
+
+// readLocalizedFile 读取目录中当前语言的 Markdown 文件（例如 README_CN.md、README.md），不存在时返回空
+func readLocalizedFile(dir, name string) string {
+	// 定义可能的文件名模式
 	patterns := []string{
-		fmt.Sprintf("README_%s.md", global.Language),
-		fmt.Sprintf("README-%s.md", global.Language),
-		fmt.Sprintf("README.%s.md", global.Language),
+		fmt.Sprintf("%s_%s.md", name, global.Language),
+		fmt.Sprintf("%s-%s.md", name, global.Language),
+		fmt.Sprintf("%s.%s.md", name, global.Language),
 	}
 	if slices.Contains([]string{"zh-cht", "zh-hk", "zh-tw"}, global.Language) {
-		patterns = append(patterns, "README_TW.md", "README-TW.md", "README.TW.md", "README_HK.md", "README-HK.md", "README.HK.md")
+		for _, suffix := range []string{"TW", "HK"} {
+			patterns = append(patterns, name+"_"+suffix+".md", name+"-"+suffix+".md", name+"."+suffix+".md")
+		}
 	}
 	if strings.HasPrefix(global.Language, "zh") {
-		patterns = append(patterns, "README_CN.md", "README-CN.md", "README.CN.md", "README_ZH.md", "README-ZH.md", "README.ZH.md")
+		for _, suffix := range []string{"CN", "ZH"} {
+			patterns = append(patterns, name+"_"+suffix+".md", name+"-"+suffix+".md", name+"."+suffix+".md")
+		}
 	}
-	patterns = append(patterns, "README.md")
+	patterns = append(patterns, name+".md")
 
 	// 获取目录中的所有文件
-	appDir := filepath.Join(global.WorkDir, "apps", appId)
-	entries, err := os.ReadDir(appDir)
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return ""
 	}
@@ -479,8 +577,7 @@ func GetReadme(appId string) string {
 	for _, pattern := range patterns {
 		lowerPattern := strings.ToLower(pattern)
 		if actualName, exists := fileMap[lowerPattern]; exists {
-			readmePath := filepath.Join(appDir, actualName)
-			if content, err := os.ReadFile(readmePath); err == nil {
+			if content, err := os.ReadFile(filepath.Join(dir, actualName)); err == nil {
 				return string(content)
 			}
 		}
@@ -490,8 +587,24 @@ func GetReadme(appId string) string {
 }
 
 // FindLatestVersion 获取应用的最新版本
+// - 只考虑订阅的发布通道内的版本
+// - 已安装且固定版本的应用返回当前安装的版本
+// - 已安装且设置了版本范围的应用返回范围内的最新版本
+// - 不考虑不兼容当前 DooTask 版本和平台的版本
 func FindLatestVersion(appId string) (string, error) {
+	appConfig := GetAppConfig(appId)
 	versions := findVersions(appId)
+	installed := appConfig.Status != "not_installed" && appConfig.InstallVersion != ""
+	if installed && appConfig.Pinned && slices.Contains(versions, appConfig.InstallVersion) {
+		return appConfig.InstallVersion, nil
+	}
+	versions = appConfig.ChannelVersions(versions)
+	if installed {
+		versions = appConfig.AllowedVersions(versions)
+	}
+	if app, err := NewApp(appId); err == nil {
+		versions = app.CompatibleVersions(versions)
+	}
 	if len(versions) == 0 {
 		return "", errors.New(i18n.T("AppVersionNotFound", appId))
 	}
@@ -574,7 +687,7 @@ func ExtractAppId(input string) string {
 
 		// 从 URL 中提取应用ID
 		if appId == "" {
-			versionRegex := regexp.MustCompile(`(?:/|_)(v?\d+(?:\.\d+){1,2}|latest)(?:/|$)`)
+			versionRegex := regexp.MustCompile(`(?:/|_)(` + utils.VersionPattern + `|latest)(?:/|$)`)
 			matches := versionRegex.FindStringSubmatch(u.Path)
 			if len(matches) > 1 {
 				// 获取版本号在路径中的位置
@@ -608,8 +721,8 @@ func ExtractAppId(input string) string {
 	// 移除压缩文件后缀
 	appId = regexp.MustCompile(`\.(?:zip|tar\.gz|tgz)$`).ReplaceAllString(appId, "")
 
-	// 匹配类似 -1.0.0, -v1.0.0, _1.0.0, _v1.0.0, -latest, _latest 的版本号
-	appId = regexp.MustCompile(`[-_](?:v?\d+(?:\.\d+){1,2}|latest)$`).ReplaceAllString(appId, "")
+	// 匹配类似 -1.0.0, -v1.0.0, _1.0.0, _v1.0.0, -1.0.0-beta.1, -latest, _latest 的版本号
+	appId = regexp.MustCompile(`[-_](?:`+utils.VersionPattern+`|latest)$`).ReplaceAllString(appId, "")
 
 	// 替换特殊字符为下划线
 	appId = regexp.MustCompile(`[^a-zA-Z0-9]`).ReplaceAllString(appId, "_")
@@ -707,12 +820,14 @@ func CheckFileTypeAndUnzip(filePath, tempDir string) (string, string, error) {
 // 1、检查config.yml文件
 // 2、如果根目录没有config.yml，检查第一个子目录
 // 3、如果子目录也没有config.yml，返回错误
-// 4、检查name字段
+// 4、检查配置项和name字段（未知的配置项只记录警告）
 // 5、检查删除apps目录下同名的应用
 // 6、移动文件到apps目录
-// 7、删除临时目录
-// 8、返回应用目录（第一个参数不为空表示成功）
-func CheckAppCompliance(appId, tempDir string) (string, string, error) {
+// 7、记录应用包来源
+// 8、清除资源包缓存
+// 9、删除临时目录
+// 10、返回应用目录（第一个参数不为空表示成功）
+func CheckAppCompliance(appId, tempDir string, source *AppSource) (string, string, error) {
 	// 闭包 删除临时目录
 	defer func() {
 		if utils.IsDirExists(tempDir) {
@@ -765,15 +880,9 @@ func CheckAppCompliance(appId, tempDir string) (string, string, error) {
 		return "", i18n.T("ReadConfigFileFailed"), err
 	}
 
-	var config map[string]interface{}
-	if err := yaml.Unmarshal(configData, &config); err != nil {
-		return "", i18n.T("YamlParseFailed", err.Error()), nil
-	}
-
-	// 检查name字段
-	name, ok := config["name"].(string)
-	if !ok || name == "" {
-		return "", i18n.T("InvalidConfig"), nil
+	// 检查配置项和name字段
+	if reason := ValidateManifest(appId, configData); reason != "" {
+		return "", reason, nil
 	}
 
 	// 应用目录
@@ -789,6 +898,14 @@ func CheckAppCompliance(appId, tempDir string) (string, string, error) {
 		return "", i18n.T("MoveFileFailed"), err
 	}
 
+	// 记录应用包来源
+	if err := SaveAppSource(appId, source); err != nil {
+		return "", i18n.T("SaveAppSourceFailed"), err
+	}
+
+	// 清除资源包缓存
+	InvalidateSourcesPackage()
+
 	// 返回应用目录
 	return appDir, "", nil
 }
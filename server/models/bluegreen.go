@@ -0,0 +1,152 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"appstore/server/utils"
+)
+
+// composeProjectSuffix 蓝绿升级时新版本使用的项目名称后缀（两个项目交替使用）
+const composeProjectSuffix = "-next"
+
+// IsBlueGreen 版本是否使用蓝绿升级（版本在 blue_green 范围内）
+func (a *App) IsBlueGreen(version string) bool {
+	return a.BlueGreen != "" && utils.CheckVersionConstraint(version, a.BlueGreen)
+}
+
+// composeProject 应用当前使用的compose项目名称
+func composeProject(appId string, config *AppConfig) string {
+	if config != nil && config.Project != "" {
+		return config.Project
+	}
+	return "dootask-app-" + appId
+}
+
+// nextComposeProject 蓝绿升级时新版本使用的compose项目名称，例如 dootask-app-okr => dootask-app-okr-next => dootask-app-okr
+func nextComposeProject(appId string, config *AppConfig) string {
+	project := composeProject(appId, config)
+	if strings.HasSuffix(project, composeProjectSuffix) {
+		return strings.TrimSuffix(project, composeProjectSuffix)
+	}
+	return project + composeProjectSuffix
+}
+
+// blueGreenAlias 蓝绿升级时服务在网络中的别名（区分新旧两个项目），例如 dootask-app-okr-next-web
+func blueGreenAlias(project, service string) string {
+	return project + "-" + service
+}
+
+// blueGreenUnsupported 蓝绿升级不支持的服务配置（新旧版本同时运行时会冲突），返回原因（为空表示支持）
+// - 没有设置 name 或 external: true 的命名卷属于compose项目，新项目会使用新的空卷
+func blueGreenUnsupported(composeMap map[string]interface{}) string {
+	volumes, _ := composeMap["volumes"].(map[string]interface{})
+	volumeNames := make([]string, 0, len(volumes))
+	for name := range volumes {
+		volumeNames = append(volumeNames, name)
+	}
+	sort.Strings(volumeNames)
+	for _, name := range volumeNames {
+		volumeMap, _ := volumes[name].(map[string]interface{})
+		if external, _ := volumeMap["external"].(bool); external {
+			continue
+		}
+		if volumeName, _ := volumeMap["name"].(string); volumeName != "" {
+			continue
+		}
+		return fmt.Sprintf("volume %s is scoped to the compose project (set name or external: true)", name)
+	}
+
+	services, _ := composeMap["services"].(map[string]interface{})
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		serviceMap, ok := services[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := serviceMap["container_name"]; ok {
+			return fmt.Sprintf("service %s sets container_name", name)
+		}
+		if ports, ok := serviceMap["ports"].([]interface{}); ok && len(ports) > 0 {
+			return fmt.Sprintf("service %s publishes host ports", name)
+		}
+	}
+	return ""
+}
+
+// rewriteNginxUpstreams 将nginx配置中代理到应用服务的地址改为项目的服务别名
+// 例如 proxy_pass http://web:8080/ => proxy_pass http://dootask-app-okr-next-web:8080/
+func rewriteNginxUpstreams(content, project string, services []string) string {
+	for _, service := range services {
+		regex := regexp.MustCompile(`(https?://)` + regexp.QuoteMeta(service) + `([:/;\s])`)
+		content = regex.ReplaceAllString(content, "${1}"+blueGreenAlias(project, service)+"${2}")
+	}
+	return content
+}
+
+// composeUpBlueGreen 蓝绿升级：新版本作为独立的compose项目启动，就绪后切换nginx，再停止旧版本，返回应用状态
+// - 新版本启动、就绪、post_upgrade 钩子或nginx配置测试失败时停止新版本，旧版本保持运行
+func composeUpBlueGreen(ctx context.Context, appId string, previous *AppConfig, env map[string]string) string {
+	appConfig := GetAppConfig(appId)
+	oldProject := composeProject(appId, previous)
+	AppLogInfo(appId, fmt.Sprintf("blue-green upgrade %s => %s", oldProject, composeProject(appId, appConfig)))
+
+	// 停止新版本，恢复旧版本的配置
+	abort := func(reason string) string {
+		AppLogError(appId, reason+", blue-green upgrade aborted")
+		if err := runComposeCommand(ctx, appId, "compose", "down", "--remove-orphans"); err != nil {
+			AppLogError(appId, "Failed to stop new version: "+err.Error())
+		}
+		return restoreRollback(ctx, appId, false)
+	}
+
+	// 升级前钩子
+	if err := RunVersionHooks(ctx, appId, appConfig.InstallVersion, HookPreUpgrade, env); err != nil {
+		AppLogError(appId, "pre_upgrade failed, upgrade aborted: "+err.Error())
+		return restoreRollback(ctx, appId, false)
+	}
+
+	// 启动新版本（旧版本继续提供服务）
+	if err := runComposeCommand(ctx, appId, "compose", "up", "-d", "--remove-orphans"); err != nil {
+		return abort("Command execution failed: " + err.Error())
+	}
+
+	// 等待新版本健康
+	if err := waitAppHealthy(ctx, appId); err != nil {
+		status := abort(err.Error())
+		setAppError(appId, err.Error())
+		return status
+	}
+
+	// 升级后钩子
+	if err := RunVersionHooks(ctx, appId, appConfig.InstallVersion, HookPostUpgrade, env); err != nil {
+		return abort("post_upgrade failed: " + err.Error())
+	}
+
+	// 切换nginx到新版本（测试失败时保留原配置，不重启nginx）
+	AppLogInfo(appId, "nginx reload starting...")
+	out, err := ApplyNginxConfig(appId, 3)
+	if out != "" {
+		AppLogInfo(appId, "nginx reload output: "+out)
+	}
+	AppLogInfo(appId, "nginx reload end")
+	if err != nil {
+		return abort("nginx reload failed: " + err.Error())
+	}
+
+	// 停止旧版本
+	oldComposeFile := filepath.Join(rollbackDir(appId), "docker-compose.yml")
+	if err := runComposeCommand(ctx, appId, "compose", "-f", oldComposeFile, "-p", oldProject, "down", "--remove-orphans"); err != nil {
+		AppLogWarn(appId, "Failed to stop previous version: "+err.Error())
+	}
+
+	return "installed"
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"path/filepath"
+	"strings"
+
+	"appstore/server/global"
+	"appstore/server/utils"
+)
+
+// VersionChangelog 版本更新日志
+type VersionChangelog struct {
+	Version string `json:"version"`
+	Content string `json:"content"`
+}
+
+// GetVersionChangelog 获取版本的更新日志
+// 1、版本目录中当前语言的 CHANGELOG.md（例如 CHANGELOG_CN.md，规则同 README）
+// 2、config.yml 中 changelog 下对应版本的内容（支持多语言）
+// 3、都没有时返回空
+func GetVersionChangelog(app *App, version string) string {
+	if !utils.IsValidVersion(version) {
+		return ""
+	}
+	content := readLocalizedFile(filepath.Join(global.WorkDir, "apps", app.ID, version), "CHANGELOG")
+	if content != "" {
+		return content
+	}
+	for key, value := range app.ChangelogBlock {
+		if strings.TrimPrefix(key, "v") == strings.TrimPrefix(version, "v") {
+			return getLocalizedValue(value, global.Language)
+		}
+	}
+	return ""
+}
+
+// GetAppChangelogs 获取应用所有版本的更新日志（从新到旧，跳过没有更新日志的版本）
+func GetAppChangelogs(app *App) []VersionChangelog {
+	changelogs := []VersionChangelog{}
+	for _, version := range app.Versions {
+		if content := GetVersionChangelog(app, version); content != "" {
+			changelogs = append(changelogs, VersionChangelog{
+				Version: version,
+				Content: content,
+			})
+		}
+	}
+	return changelogs
+}
+
+// GetAggregatedChangelogs 获取两个版本之间的更新日志（不含 from，含 to，从新到旧）
+func GetAggregatedChangelogs(app *App, from, to string) []VersionChangelog {
+	changelogs := []VersionChangelog{}
+	for _, changelog := range GetAppChangelogs(app) {
+		if utils.CompareVersions(changelog.Version, from) > 0 && utils.CompareVersions(changelog.Version, to) <= 0 {
+			changelogs = append(changelogs, changelog)
+		}
+	}
+	return changelogs
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"appstore/server/i18n"
+	"appstore/server/utils"
+)
+
+// VersionCompatibility 定义版本的兼容性要求结构
+type VersionCompatibility struct {
+	DooTaskVersion string   `yaml:"dootask_version" json:"dootask_version"` // 要求的 DooTask 版本范围，例如 >=1.0
+	Platforms      []string `yaml:"platforms" json:"platforms"`             // 支持的平台，例如 linux/amd64、linux/arm64，为空表示所有平台
+}
+
+// platformAliases 平台架构别名
+var platformAliases = map[string]string{
+	"x86_64":  "amd64",
+	"x86-64":  "amd64",
+	"aarch64": "arm64",
+	"armv7":   "arm",
+	"armhf":   "arm",
+}
+
+var (
+	dootaskVersionMutex   sync.Mutex
+	dootaskVersionCache   string
+	dootaskVersionExpires time.Time
+)
+
+// CurrentPlatform 当前运行的平台，例如 linux/amd64
+func CurrentPlatform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// GetDooTaskVersion 获取正在运行的 DooTask 版本，获取失败时返回空
+// - 优先使用环境变量 DOOTASK_VERSION
+// - 否则请求 DooTask 接口获取（结果缓存10分钟）
+func GetDooTaskVersion() string {
+	if version := strings.TrimSpace(os.Getenv("DOOTASK_VERSION")); version != "" {
+		return version
+	}
+
+	dootaskVersionMutex.Lock()
+	defer dootaskVersionMutex.Unlock()
+	if time.Now().Before(dootaskVersionExpires) {
+		return dootaskVersionCache
+	}
+
+	dootaskVersionCache = ""
+	dootaskVersionExpires = time.Now().Add(DooTaskCacheTime)
+	client := &http.Client{
+		Timeout: 3 * time.Second,
+	}
+	resp, err := client.Get(DooTaskServer + "/api/system/version")
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		DooTaskResponse
+		Data struct {
+			Version string `json:"version"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil || response.Ret != 1 {
+		return ""
+	}
+	if utils.IsValidVersion(response.Data.Version) {
+		dootaskVersionCache = response.Data.Version
+	}
+	return dootaskVersionCache
+}
+
+// matchPlatform 判断平台是否匹配，例如 linux/arm64/v8 匹配 linux/arm64，只写架构时默认 linux
+func matchPlatform(platform, current string) bool {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(platform)), "/")
+	if len(parts) == 1 {
+		parts = []string{"linux", parts[0]}
+	}
+	if alias, ok := platformAliases[parts[1]]; ok {
+		parts[1] = alias
+	}
+	currentParts := strings.Split(current, "/")
+	return parts[0] == currentParts[0] && parts[1] == currentParts[1]
+}
+
+// VersionRequirement 获取版本的兼容性要求，compatibility 中的版本配置覆盖应用配置
+func (a *App) VersionRequirement(version string) VersionCompatibility {
+	requirement := VersionCompatibility{
+		DooTaskVersion: a.DooTaskVersion,
+		Platforms:      a.Platforms,
+	}
+	if override, ok := a.Compatibility[version]; ok {
+		if override.DooTaskVersion != "" {
+			requirement.DooTaskVersion = override.DooTaskVersion
+		}
+		if override.Platforms != nil {
+			requirement.Platforms = override.Platforms
+		}
+	}
+	return requirement
+}
+
+// VersionIncompatibility 检查版本是否兼容当前 DooTask 版本和平台，兼容时返回空，否则返回原因
+// - 获取不到 DooTask 版本时不检查 DooTask 版本
+func (a *App) VersionIncompatibility(version string) string {
+	requirement := a.VersionRequirement(version)
+	if requirement.DooTaskVersion != "" {
+		if current := GetDooTaskVersion(); current != "" && !utils.CheckVersionConstraint(current, requirement.DooTaskVersion) {
+			return i18n.T("RequireDooTaskVersion", map[string]interface{}{
+				"range":   requirement.DooTaskVersion,
+				"current": current,
+			})
+		}
+	}
+	if len(requirement.Platforms) > 0 {
+		current := CurrentPlatform()
+		if !slices.ContainsFunc(requirement.Platforms, func(platform string) bool {
+			return matchPlatform(platform, current)
+		}) {
+			return i18n.T("UnsupportedPlatform", map[string]interface{}{
+				"platform":  current,
+				"platforms": strings.Join(requirement.Platforms, ", "),
+			})
+		}
+	}
+	return ""
+}
+
+// CompatibleVersions 过滤出兼容当前 DooTask 版本和平台的版本
+func (a *App) CompatibleVersions(versions []string) []string {
+	compatible := []string{}
+	for _, version := range versions {
+		if _, ok := a.IncompatibleVersions[version]; !ok {
+			compatible = append(compatible, version)
+		}
+	}
+	return compatible
+}
+
+// checkCompatibility 检查安装的版本是否兼容，不兼容时返回原因
+func checkCompatibility(app *App, version string) (string, error) {
+	reason := app.VersionIncompatibility(version)
+	if reason == "" {
+		return "", nil
+	}
+	message := i18n.T("AppVersionIncompatible", map[string]interface{}{
+		"version": version,
+		"reason":  reason,
+	})
+	return message, errors.New(message)
+}
+
+// markCompatibility 标记应用不兼容的版本，所有版本都不兼容时应用不兼容（原因取最新版本的原因）
+func (a *App) markCompatibility() {
+	a.IncompatibleVersions = map[string]string{}
+	for _, version := range a.Versions {
+		if reason := a.VersionIncompatibility(version); reason != "" {
+			a.IncompatibleVersions[version] = reason
+		}
+	}
+	a.Compatible = len(a.IncompatibleVersions) == 0 || len(a.IncompatibleVersions) < len(a.Versions)
+	if !a.Compatible {
+		a.IncompatibleReason = a.IncompatibleVersions[a.Versions[0]]
+	}
+}
@@ -0,0 +1,365 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"appstore/server/global"
+	"appstore/server/i18n"
+	"appstore/server/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AppConflict 定义与其他应用冲突的结构
+type AppConflict struct {
+	App     string      `yaml:"app" json:"app"`         // 冲突的应用ID
+	Version string      `yaml:"version" json:"version"` // 冲突的版本范围，例如 <2.0，为空表示所有版本
+	Reason  interface{} `yaml:"reason" json:"reason"`   // 冲突原因（支持多语言）
+}
+
+// 冲突类型
+const (
+	ConflictTypeApp      = "app"      // 应用声明的冲突
+	ConflictTypeService  = "service"  // 共享网络中的服务名称或容器名称重复
+	ConflictTypePort     = "port"     // 主机端口重复
+	ConflictTypeLocation = "location" // nginx location 重叠
+	ConflictTypeCore     = "core"     // nginx location 与 DooTask 核心路由重叠
+)
+
+// dootaskLocations DooTask 核心路由，应用的 location 不能与其重叠（= 表示只保留该路径本身，应用可以使用其子路径）
+var dootaskLocations = []nginxLocate{
+	{Modifier: "=", Path: "/"},
+	{Modifier: "=", Path: "/apps/"},
+	{Path: "/api/"},
+	{Path: "/ws"},
+	{Path: "/uploads/"},
+	{Path: "/appstore/"},
+	{Path: "/index.php"},
+}
+
+// ConflictIssue 检测到的冲突
+type ConflictIssue struct {
+	Type  string `json:"type"`  // app, service, port, location, core
+	App   string `json:"app"`   // 冲突的已安装应用
+	Value string `json:"value"` // 冲突的服务名称、端口、location 或声明的冲突原因
+}
+
+// Message 冲突的说明
+func (c ConflictIssue) Message() string {
+	key := map[string]string{
+		ConflictTypeApp:      "ConflictDeclared",
+		ConflictTypeService:  "ConflictService",
+		ConflictTypePort:     "ConflictPort",
+		ConflictTypeLocation: "ConflictLocation",
+		ConflictTypeCore:     "ConflictCoreLocation",
+	}[c.Type]
+	return i18n.T(key, map[string]interface{}{
+		"app":   c.App,
+		"value": c.Value,
+	})
+}
+
+// appClaims 应用占用的共享资源
+type appClaims struct {
+	Services  []string      // 服务名称和容器名称
+	Ports     []string      // 主机端口，例如 8080/tcp
+	Locations []nginxLocate // nginx location
+}
+
+// nginxLocate nginx location 定义
+type nginxLocate struct {
+	Modifier string // 修饰符：空、=、^~、~、~*
+	Path     string
+}
+
+// coreLocation 与 location 重叠的 DooTask 核心路由，没有时返回false（正则 location 无法判断，不检查）
+func (l nginxLocate) coreLocation() (nginxLocate, bool) {
+	if strings.HasPrefix(l.Modifier, "~") {
+		return nginxLocate{}, false
+	}
+	for _, core := range dootaskLocations {
+		if core.Modifier == "=" && l.Path == core.Path || core.Modifier != "=" && l.shadows(core) {
+			return core, true
+		}
+	}
+	return nginxLocate{}, false
+}
+
+// String location 的显示内容
+func (l nginxLocate) String() string {
+	if l.Modifier == "" {
+		return l.Path
+	}
+	return l.Modifier + " " + l.Path
+}
+
+// shadows location 是否会接管 other 匹配的请求（nginx 精确匹配优先，前缀 location 最长匹配优先）
+// - 正则 location 相同时接管
+// - 精确 location 只被相同的精确 location 接管
+// - 路径相同或位于 other 路径之下（更长的前缀）时接管
+func (l nginxLocate) shadows(other nginxLocate) bool {
+	lRegex := strings.HasPrefix(l.Modifier, "~")
+	otherRegex := strings.HasPrefix(other.Modifier, "~")
+	if lRegex || otherRegex {
+		return lRegex == otherRegex && l.Path == other.Path
+	}
+	if other.Modifier == "=" {
+		return l.Modifier == "=" && l.Path == other.Path
+	}
+	return pathCovers(other.Path, l.Path)
+}
+
+// overlaps 两个 location 是否重叠：任意一方会接管另一方匹配的请求
+func (l nginxLocate) overlaps(other nginxLocate) bool {
+	return l.shadows(other) || other.shadows(l)
+}
+
+// pathCovers 前缀是否覆盖路径（按路径段比较：相同，或前缀以 / 结尾，或路径在前缀之后以 / 继续），例如 /ws 覆盖 /ws/chat，不覆盖 /wsdl
+func pathCovers(prefix, path string) bool {
+	if path == prefix {
+		return true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+var nginxLocationRegex = regexp.MustCompile(`(?m)^\s*location\s+(=|\^~|~\*|~)?\s*("[^"]+"|[^\s{]+)\s*\{`)
+
+// parseNginxLocations 解析nginx配置中的 location
+func parseNginxLocations(content string) []nginxLocate {
+	// 去掉注释
+	content = regexp.MustCompile(`(?m)#.*$`).ReplaceAllString(content, "")
+
+	locations := []nginxLocate{}
+	for _, matches := range nginxLocationRegex.FindAllStringSubmatch(content, -1) {
+		locations = append(locations, nginxLocate{
+			Modifier: matches[1],
+			Path:     strings.Trim(matches[2], `"`),
+		})
+	}
+	return locations
+}
+
+// parseComposeClaims 解析docker-compose配置中的服务名称、容器名称和主机端口
+func parseComposeClaims(composeMap map[string]interface{}) appClaims {
+	claims := appClaims{}
+	services, _ := composeMap["services"].(map[string]interface{})
+	for serviceName, service := range services {
+		claims.Services = append(claims.Services, serviceName)
+		serviceMap, ok := service.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if containerName, ok := serviceMap["container_name"].(string); ok && containerName != serviceName {
+			claims.Services = append(claims.Services, containerName)
+		}
+		ports, _ := serviceMap["ports"].([]interface{})
+		for _, port := range ports {
+			claims.Ports = append(claims.Ports, parseHostPorts(port)...)
+		}
+	}
+	slices.Sort(claims.Services)
+	slices.Sort(claims.Ports)
+	claims.Ports = slices.Compact(claims.Ports)
+	return claims
+}
+
+// parseHostPorts 解析端口映射中的主机端口，例如 "127.0.0.1:8080:80/udp" => [8080/udp]
+// - 只有容器端口（没有映射到主机）时返回空
+// - 支持端口范围（例如 8000-8010:8000-8010）和长语法（published、protocol）
+func parseHostPorts(port interface{}) []string {
+	var published, protocol string
+	switch value := port.(type) {
+	case string:
+		mapping := value
+		if index := strings.LastIndex(mapping, "/"); index >= 0 {
+			mapping, protocol = mapping[:index], mapping[index+1:]
+		}
+		index := strings.LastIndex(mapping, ":")
+		if index < 0 {
+			return nil
+		}
+		published = mapping[:index]
+		if index = strings.LastIndex(published, ":"); index >= 0 {
+			published = published[index+1:]
+		}
+	case map[string]interface{}:
+		if value["published"] == nil {
+			return nil
+		}
+		published = fmt.Sprintf("%v", value["published"])
+		protocol, _ = value["protocol"].(string)
+	default:
+		return nil
+	}
+	if protocol == "" {
+		protocol = "tcp"
+	}
+
+	// 解析端口范围
+	start, end, found := strings.Cut(published, "-")
+	startPort, err := strconv.Atoi(start)
+	if err != nil {
+		return nil
+	}
+	endPort := startPort
+	if found {
+		if endPort, err = strconv.Atoi(end); err != nil || endPort < startPort || endPort-startPort > 1000 {
+			return nil
+		}
+	}
+	hostPorts := []string{}
+	for p := startPort; p <= endPort; p++ {
+		hostPorts = append(hostPorts, fmt.Sprintf("%d/%s", p, protocol))
+	}
+	return hostPorts
+}
+
+// installedAppClaims 读取已安装应用生成的配置，获取占用的共享资源
+func installedAppClaims(appId string) appClaims {
+	claims := appClaims{}
+	configDir := filepath.Join(global.WorkDir, "config", appId)
+	if data, err := os.ReadFile(filepath.Join(configDir, "docker-compose.yml")); err == nil {
+		composeMap := make(map[string]interface{})
+		if yaml.Unmarshal(data, &composeMap) == nil {
+			claims = parseComposeClaims(composeMap)
+		}
+	}
+	// 安装中的应用使用待生效的nginx配置
+	nginxConfigPath := nginxStagedPath(appId, "nginx.conf")
+	if !utils.IsFileExists(nginxConfigPath) {
+		nginxConfigPath = filepath.Join(configDir, "nginx.conf")
+	}
+	if data, err := os.ReadFile(nginxConfigPath); err == nil {
+		claims.Locations = parseNginxLocations(string(data))
+	}
+	return claims
+}
+
+// versionAppClaims 获取安装应用版本后将占用的共享资源
+func versionAppClaims(appId, version string, params map[string]interface{}) (appClaims, error) {
+	composeMap, err := renderDockerComposeTemplate(appId, version, params)
+	if err != nil {
+		return appClaims{}, err
+	}
+	claims := parseComposeClaims(composeMap)
+	content, err := renderNginxTemplate(appId, version, params)
+	if err != nil {
+		return appClaims{}, err
+	}
+	claims.Locations = parseNginxLocations(content)
+	return claims, nil
+}
+
+// FindConflicts 检测安装应用版本与已安装（或安装中）的其他应用之间的冲突
+// - 双方 conflicts 中声明的冲突
+// - 共享网络中重复的服务名称、容器名称
+// - 重复的主机端口
+// - 重叠的 nginx location（包括与 DooTask 核心路由重叠）
+func FindConflicts(app *App, version string, params map[string]interface{}) ([]ConflictIssue, error) {
+	claims, err := versionAppClaims(app.ID, version, params)
+	if err != nil {
+		return nil, err
+	}
+	// 子域名路由使用独立的 server 块，不占用 DooTask 的路由
+	if app.IsSubdomainRouting() {
+		claims.Locations = nil
+	}
+
+	issues := []ConflictIssue{}
+	for _, location := range claims.Locations {
+		if core, ok := location.coreLocation(); ok {
+			value := location.String()
+			if core != location {
+				value += " (" + core.String() + ")"
+			}
+			issues = append(issues, ConflictIssue{Type: ConflictTypeCore, App: "DooTask", Value: value})
+		}
+	}
+	for _, other := range NewApps(nil) {
+		if other.ID == app.ID || (other.Config.Status != "installed" && other.Config.Status != "installing") {
+			continue
+		}
+
+		// 声明的冲突
+		for _, conflict := range app.Conflicts {
+			if conflict.App == other.ID && utils.CheckVersionConstraint(other.Config.InstallVersion, conflict.Version) {
+				issues = append(issues, ConflictIssue{Type: ConflictTypeApp, App: other.ID, Value: conflictReason(conflict)})
+			}
+		}
+		for _, conflict := range other.Conflicts {
+			if conflict.App == app.ID && utils.CheckVersionConstraint(version, conflict.Version) {
+				issues = append(issues, ConflictIssue{Type: ConflictTypeApp, App: other.ID, Value: conflictReason(conflict)})
+			}
+		}
+
+		// 共享资源冲突
+		otherClaims := installedAppClaims(other.ID)
+		for _, service := range claims.Services {
+			if slices.Contains(otherClaims.Services, service) {
+				issues = append(issues, ConflictIssue{Type: ConflictTypeService, App: other.ID, Value: service})
+			}
+		}
+		for _, port := range claims.Ports {
+			if slices.Contains(otherClaims.Ports, port) {
+				issues = append(issues, ConflictIssue{Type: ConflictTypePort, App: other.ID, Value: port})
+			}
+		}
+		for _, location := range claims.Locations {
+			for _, otherLocation := range otherClaims.Locations {
+				if location.overlaps(otherLocation) {
+					value := location.String()
+					if otherLocation != location {
+						value += " (" + otherLocation.String() + ")"
+					}
+					issues = append(issues, ConflictIssue{Type: ConflictTypeLocation, App: other.ID, Value: value})
+				}
+			}
+		}
+	}
+	return issues, nil
+}
+
+// conflictReason 声明的冲突原因，没有原因时返回冲突的版本范围
+func conflictReason(conflict AppConflict) string {
+	if reason := getLocalizedValue(conflict.Reason, global.Language); reason != "" {
+		return reason
+	}
+	if conflict.Version != "" {
+		return conflict.Version
+	}
+	return "-"
+}
+
+// checkConflicts 检查安装应用版本是否存在冲突，存在时返回冲突说明
+func checkConflicts(app *App, version string, params map[string]interface{}) (string, error) {
+	for _, conflict := range app.Conflicts {
+		if conflict.Version != "" {
+			if err := utils.ValidateVersionConstraint(conflict.Version); err != nil {
+				return i18n.T("InvalidVersionRange", conflict.Version), err
+			}
+		}
+	}
+	issues, err := FindConflicts(app, version, params)
+	if err != nil {
+		return err.Error(), err
+	}
+	if len(issues) == 0 {
+		return "", nil
+	}
+	messages := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		messages = append(messages, issue.Message())
+	}
+	message := i18n.T("AppConflictsFound", strings.Join(messages, "; "))
+	return message, errors.New(message)
+}
@@ -1,8 +1,8 @@
 package models
 
 import (
-	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -65,38 +65,58 @@ func convertVolumePath(volume string, versionPwd string) string {
 	return fmt.Sprintf("%s:%s", src, dst)
 }
 
-// GenerateDockerCompose 生成docker-compose.yml文件
-func GenerateDockerCompose(appId string, version string, config *AppConfig) error {
+// renderTemplateParams 将模板中的 ${KEY} 替换为应用参数的值（docker-compose.yml 和 nginx.conf 共用）
+// - 只替换应用参数中存在的 KEY，其他占位符（例如nginx变量 ${host}）保持不变
+func renderTemplateParams(content string, params map[string]interface{}) string {
+	for key, value := range params {
+		content = strings.ReplaceAll(content, "${"+key+"}", fmt.Sprintf("%v", value))
+	}
+	return content
+}
+
+// renderDockerComposeTemplate 读取应用版本的docker-compose.yml模板，替换参数后解析
+func renderDockerComposeTemplate(appId string, version string, params map[string]interface{}) (map[string]interface{}, error) {
 	// 读取应用的docker-compose.yml模板
 	templatePath := filepath.Join(global.WorkDir, "apps", appId, version, "docker-compose.yml")
 	templateData, err := os.ReadFile(templatePath)
 	if err != nil {
-		return errors.New(i18n.T("ReadDockerComposeTemplateFailed", err))
+		return nil, errors.New(i18n.T("ReadDockerComposeTemplateFailed", err))
 	}
 	composeData := string(templateData)
 
 	// 处理环境变量
 	composeData = strings.ReplaceAll(composeData, "${HOST_PWD}", "")
 	composeData = strings.ReplaceAll(composeData, "${PUBLIC_PATH}", "${HOST_PWD}/public")
-	if config.Params != nil {
-		for key, value := range config.Params {
-			composeData = strings.ReplaceAll(composeData, "${"+key+"}", fmt.Sprintf("%v", value))
-		}
-	}
+	composeData = renderTemplateParams(composeData, params)
 
 	// 解析模板
 	composeMap := make(map[string]interface{})
 	if err = yaml.Unmarshal([]byte(composeData), &composeMap); err != nil {
-		return errors.New(i18n.T("ParseDockerComposeTemplateFailed", err))
+		return nil, errors.New(i18n.T("ParseDockerComposeTemplateFailed", err))
 	}
 
 	// 检查services配置是否存在
 	if _, ok := composeMap["services"].(map[string]interface{}); !ok {
-		return errors.New(i18n.T("InvalidConfiguration"))
+		return nil, errors.New(i18n.T("InvalidConfiguration"))
 	}
+	return composeMap, nil
+}
 
-	// 服务名称
-	composeMap["name"] = "dootask-app-" + appId
+// GenerateDockerCompose 生成docker-compose.yml文件
+func GenerateDockerCompose(appId string, version string, config *AppConfig) error {
+	composeMap, err := renderDockerComposeTemplate(appId, version, config.Params)
+	if err != nil {
+		return err
+	}
+
+	// 项目名称
+	composeMap["name"] = composeProject(appId, config)
+
+	// 蓝绿升级的版本为服务添加项目别名，nginx 通过别名区分新旧版本
+	blueGreen := false
+	if app, err := NewApp(appId); err == nil {
+		blueGreen = app.IsBlueGreen(version)
+	}
 
 	// 网络名称
 	networkName := "dootask-networks-" + os.Getenv("APP_ID")
@@ -127,6 +147,13 @@ func GenerateDockerCompose(appId string, version string, config *AppConfig) erro
 
 		// 确保所有服务都有网络配置
 		serviceMap["networks"] = []string{networkName}
+		if blueGreen {
+			serviceMap["networks"] = map[string]interface{}{
+				networkName: map[string]interface{}{
+					"aliases": []string{blueGreenAlias(composeMap["name"].(string), serviceName)},
+				},
+			}
+		}
 
 		// 处理挂载路径
 		if serviceMap["volumes"] != nil {
@@ -182,8 +209,8 @@ func GenerateDockerCompose(appId string, version string, config *AppConfig) erro
 	return nil
 }
 
-// RunDockerCompose 执行docker-compose up/down命令
-func RunDockerCompose(appId, action string) error {
+// RunDockerCompose 执行docker-compose up/down命令（force 只用于 down，卸载前钩子失败时继续卸载）
+func RunDockerCompose(appId, action string, force bool) error {
 	// 切换到应用配置目录
 	configDir := filepath.Join(global.WorkDir, "config", appId)
 	if err := os.Chdir(configDir); err != nil {
@@ -192,6 +219,8 @@ func RunDockerCompose(appId, action string) error {
 
 	// 更新状态
 	appConfig := GetAppConfig(appId)
+	appConfig.Error = ""
+	appConfig.Degraded = ""
 	if action == "up" {
 		appConfig.Status = "installing"
 		appConfig.InstallAt = time.Now().Format("2006-01-02 15:04:05")
@@ -203,6 +232,9 @@ func RunDockerCompose(appId, action string) error {
 		return errors.New(i18n.T("UpdateAppStatusFailed", err))
 	}
 
+	// 清除容器守护的故障记录（安装完成后重新开始统计）
+	resetSupervisedApp(appId)
+
 	// 写入日志
 	AppLogInfo(appId, action+" starting...")
 
@@ -213,67 +245,17 @@ func RunDockerCompose(appId, action string) error {
 		defer cancel()
 
 		// 执行docker-compose命令
-		var cmd *exec.Cmd
 		var status string
 		if action == "up" {
-			cmd = exec.CommandContext(ctx, "docker", "compose", "up", "-d", "--remove-orphans")
-			status = "installed"
+			status = composeUp(ctx, appId)
 		} else if action == "down" {
-			cmd = exec.CommandContext(ctx, "docker", "compose", "down", "--remove-orphans")
-			status = "not_installed"
+			status = composeDown(ctx, appId, force)
 		} else {
 			return
 		}
 
-		// 创建管道来捕获输出
-		stdout, _ := cmd.StdoutPipe()
-		stderr, _ := cmd.StderrPipe()
-		if err := cmd.Start(); err != nil {
-			AppLogError(appId, "Failed to start command: "+err.Error())
-			status = "error"
-		} else {
-			// 读取并记录输出
-			go func() {
-				scanner := bufio.NewScanner(stdout)
-				for scanner.Scan() {
-					AppLogInfo(appId, scanner.Text())
-				}
-			}()
-			go func() {
-				scanner := bufio.NewScanner(stderr)
-				for scanner.Scan() {
-					AppLogWarn(appId, scanner.Text())
-				}
-			}()
-
-			if err := cmd.Wait(); err != nil {
-				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-					AppLogError(appId, "Command execution timeout after 30 minutes")
-				} else {
-					AppLogError(appId, "Command execution failed: "+err.Error())
-				}
-				status = "error"
-			}
-		}
-
 		AppLogInfo(appId, action+" "+status)
 
-		if status == "installed" {
-			// 重启nginx
-			if hasNginxConfig, _ := HasNginxConfig(appId); hasNginxConfig {
-				AppLogInfo(appId, "nginx reload starting...")
-				out, err := ReloadNginx(appId, 3)
-				if out != "" {
-					AppLogInfo(appId, "nginx reload output: "+out)
-				}
-				if err != nil {
-					AppLogError(appId, "nginx reload failed: "+err.Error())
-					status = "error"
-				}
-				AppLogInfo(appId, "nginx reload end")
-			}
-		}
-
 		// 更新应用状态
 		appConfig := GetAppConfig(appId)
 		appConfig.Status = status
@@ -285,72 +267,203 @@ func RunDockerCompose(appId, action string) error {
 	return nil
 }
 
-// StartCheckContainerStatusDaemon 启动检测容器状态守护
-// - 如果 config.status=installed 且 docker-compose.yml 文件存在时，检查容器不存在则自动启动
-// - 每隔10秒检查一次
-// - 1分钟内只启动一次
-func StartCheckContainerStatusDaemon() {
-	configDir := filepath.Join(global.WorkDir, "config")
-	lastUpTimes := make(map[string]time.Time) // 记录每个应用最后一次执行 up 命令的时间
-	waitTime := 10 * time.Second              // 等待时间
-
-	for {
-		// 遍历 configsDir 目录下的所有子目录
-		entries, err := os.ReadDir(configDir)
-		if err != nil {
-			fmt.Printf("[Daemon] Failed to read directory config: %v\n", err)
-			time.Sleep(waitTime)
-			continue
+// composeUp 启动应用容器，返回应用状态
+// - 升级时（存在回滚备份）先执行新版本的 pre_upgrade 钩子，启动后执行 post_upgrade 钩子
+// - 升级过程中任一步骤失败时回滚到升级前的版本
+func composeUp(ctx context.Context, appId string) string {
+	appConfig := GetAppConfig(appId)
+	previous := getRollbackConfig(appId)
+	defer removeRollback(appId)
+	defer removeStagedNginxConfig(appId)
+
+	env := map[string]string{"TO_VERSION": appConfig.InstallVersion}
+	if previous != nil {
+		env["FROM_VERSION"] = previous.InstallVersion
+	}
+
+	// 蓝绿升级（新版本使用另一个compose项目）
+	if previous != nil && composeProject(appId, previous) != composeProject(appId, appConfig) {
+		return composeUpBlueGreen(ctx, appId, previous, env)
+	}
+
+	// 升级前钩子，失败时不启动新版本（旧版本容器保持运行）
+	if previous != nil {
+		if err := RunVersionHooks(ctx, appId, appConfig.InstallVersion, HookPreUpgrade, env); err != nil {
+			AppLogError(appId, "pre_upgrade failed, upgrade aborted: "+err.Error())
+			return restoreRollback(ctx, appId, false)
 		}
+	}
 
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				continue
+	// 替换容器期间显示维护页面，结束时（包括失败）恢复原配置
+	maintenance := startMaintenance(appId)
+	defer func() {
+		if stopMaintenance(appId) {
+			if out, err := ReloadNginx(appId, 3); err != nil {
+				AppLogError(appId, "nginx reload failed: "+out+" "+err.Error())
 			}
+		}
+	}()
+
+	// 启动容器
+	if err := runComposeCommand(ctx, appId, "compose", "up", "-d", "--remove-orphans"); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			AppLogError(appId, "Command execution timeout after 30 minutes")
+		} else {
+			AppLogError(appId, "Command execution failed: "+err.Error())
+		}
+		if previous != nil {
+			return restoreRollback(ctx, appId, true)
+		}
+		stopFailedInstall(ctx, appId)
+		return "error"
+	}
+
+	// 升级后钩子，失败时回滚
+	if previous != nil {
+		if err := RunVersionHooks(ctx, appId, appConfig.InstallVersion, HookPostUpgrade, env); err != nil {
+			AppLogError(appId, "post_upgrade failed, rolling back: "+err.Error())
+			return restoreRollback(ctx, appId, true)
+		}
+	}
+
+	// 等待应用健康，失败时回滚（首次安装时停止容器并标记为错误）
+	AppLogInfo(appId, "health check starting...")
+	if err := waitAppHealthy(ctx, appId); err != nil {
+		AppLogError(appId, err.Error())
+		if previous != nil {
+			status := restoreRollback(ctx, appId, true)
+			setAppError(appId, err.Error())
+			return status
+		}
+		stopFailedInstall(ctx, appId)
+		setAppError(appId, err.Error())
+		return "error"
+	}
+	AppLogInfo(appId, "health check passed")
 
-			appId := entry.Name()
+	// 恢复维护前的nginx配置（随后替换为新配置）
+	if maintenance {
+		stopMaintenance(appId)
+	}
 
-			// 检查是否在1分钟内执行过
-			if lastTime, exists := lastUpTimes[appId]; exists {
-				if time.Since(lastTime) < time.Minute {
-					continue
+	// 测试并启用新的nginx配置，重启nginx（测试失败时保留原配置，不重启nginx，升级时回滚）
+	if HasNginxConfig(appId) || hasStagedNginxConfig(appId) {
+		AppLogInfo(appId, "nginx reload starting...")
+		out, err := ApplyNginxConfig(appId, 3)
+		if out != "" {
+			AppLogInfo(appId, "nginx reload output: "+out)
+		}
+		AppLogInfo(appId, "nginx reload end")
+		if err != nil {
+			AppLogError(appId, "nginx reload failed: "+err.Error())
+			// 升级时回滚到升级前的版本
+			if previous != nil {
+				status := restoreRollback(ctx, appId, true)
+				setAppError(appId, err.Error())
+				return status
+			}
+			// 维护页面仍在生效，重新加载恢复后的原配置
+			if maintenance {
+				if out, err := ReloadNginx(appId, 3); err != nil {
+					AppLogError(appId, "nginx reload failed: "+out+" "+err.Error())
 				}
 			}
+			stopFailedInstall(ctx, appId)
+			setAppError(appId, err.Error())
+			return "error"
+		}
+	}
 
-			// 检查是否存在 docker-compose.yml 文件
-			composeFile := filepath.Join(configDir, appId, "docker-compose.yml")
-			if !utils.IsFileExists(composeFile) {
-				continue
-			}
+	return "installed"
+}
 
-			// 检查是否运行状态
-			appConfig := GetAppConfig(appId)
-			if appConfig.Status != "installed" {
-				continue
-			}
+// stopFailedInstall 首次安装失败时停止并删除已启动的容器（应用标记为错误，不再占用资源）
+func stopFailedInstall(ctx context.Context, appId string) {
+	AppLogInfo(appId, "install failed, stopping containers")
+	if err := runComposeCommand(ctx, appId, "compose", "down", "--remove-orphans"); err != nil {
+		AppLogError(appId, "Failed to stop containers: "+err.Error())
+	}
+}
 
-			// 检查容器状态
-			stdout, err := utils.Execf("docker compose -f %s ps --format {{.Name}} 2>/dev/null", composeFile)
-			if err != nil || strings.TrimSpace(stdout) != "" {
-				continue
-			}
+// setAppError 记录应用最近一次安装失败的原因
+func setAppError(appId, message string) {
+	appConfig := GetAppConfig(appId)
+	appConfig.Error = message
+	if err := SaveAppConfig(appId, appConfig); err != nil {
+		AppLogError(appId, "Failed to save error message: "+err.Error())
+	}
+}
 
-			// 如果容器不存在，则执行 up 命令
-			AppLogInfo(appId, "[Daemon] up starting...")
-			stdout, err = utils.Execf("docker compose -f %s up -d --remove-orphans", composeFile)
-			if stdout != "" {
-				AppLogInfo(appId, "[Daemon] up output: "+stdout)
-			}
-			if err != nil {
-				AppLogError(appId, "[Daemon] up failed")
-			} else {
-				AppLogInfo(appId, "[Daemon] up successful")
-			}
+// composeDown 停止并删除应用容器，返回应用状态
+// - 先执行已安装版本的 pre_uninstall 钩子，失败时取消卸载（强制卸载时继续，例如容器已崩溃无法执行钩子）
+func composeDown(ctx context.Context, appId string, force bool) string {
+	appConfig := GetAppConfig(appId)
+
+	// 依赖安装失败的应用尚未生成编排文件，没有需要停止的容器
+	if _, err := os.Stat(filepath.Join(global.WorkDir, "config", appId, "docker-compose.yml")); os.IsNotExist(err) {
+		return "not_installed"
+	}
 
-			// 记录每个应用最后一次执行 up 命令的时间
-			lastUpTimes[appId] = time.Now()
+	// 卸载前钩子
+	env := map[string]string{"FROM_VERSION": appConfig.InstallVersion}
+	if err := RunVersionHooks(ctx, appId, appConfig.InstallVersion, HookPreUninstall, env); err != nil {
+		if !force {
+			AppLogError(appId, "pre_uninstall failed, uninstall aborted: "+err.Error())
+			return "installed"
 		}
+		AppLogWarn(appId, "pre_uninstall failed, force uninstall continues: "+err.Error())
+	}
+
+	// 删除nginx配置
+	DeleteNginxConfig(appId)
+
+	// 停止容器
+	if err := runComposeCommand(ctx, appId, "compose", "down", "--remove-orphans"); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			AppLogError(appId, "Command execution timeout after 30 minutes")
+		} else {
+			AppLogError(appId, "Command execution failed: "+err.Error())
+		}
+		return "error"
+	}
 
-		time.Sleep(waitTime)
+	return "not_installed"
+}
+
+// composeContainer docker compose ps 输出的容器状态
+type composeContainer struct {
+	Name     string `json:"Name"`
+	Service  string `json:"Service"`
+	State    string `json:"State"`  // running、exited、restarting 等
+	Health   string `json:"Health"` // healthy、unhealthy、starting，未配置健康检查时为空
+	ExitCode int    `json:"ExitCode"`
+}
+
+// composeContainers 获取应用的容器状态
+func composeContainers(ctx context.Context, appId string) ([]composeContainer, error) {
+	cmd := exec.CommandContext(ctx, "docker", "compose", "ps", "-a", "--format", "json")
+	cmd.Dir = filepath.Join(global.WorkDir, "config", appId)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	// 旧版本输出JSON数组，新版本每行输出一个JSON对象
+	containers := []composeContainer{}
+	output = []byte(strings.TrimSpace(string(output)))
+	if len(output) > 0 && output[0] == '[' {
+		err = json.Unmarshal(output, &containers)
+		return containers, err
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		container := composeContainer{}
+		if err := json.Unmarshal([]byte(line), &container); err != nil {
+			return nil, err
+		}
+		containers = append(containers, container)
 	}
+	return containers, nil
 }
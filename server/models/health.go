@@ -0,0 +1,234 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"appstore/server/global"
+	"appstore/server/i18n"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 健康检查类型
+const (
+	HealthCheckHTTP    = "http"    // 请求服务的HTTP地址，返回2xx或3xx表示健康
+	HealthCheckTCP     = "tcp"     // 连接服务的端口，连接成功表示健康
+	HealthCheckCommand = "command" // 在服务容器内执行命令，退出码为0表示健康
+)
+
+// HealthCheckTypes 支持的健康检查类型
+var HealthCheckTypes = []string{HealthCheckHTTP, HealthCheckTCP, HealthCheckCommand}
+
+// healthDefaultTimeout 等待应用健康的默认超时时间（秒）
+const healthDefaultTimeout = 120
+
+// healthProbeTimeout 单次检查的超时时间
+const healthProbeTimeout = 5 * time.Second
+
+// healthInterval 检查间隔
+const healthInterval = 2 * time.Second
+
+// HealthCheck 应用健康检查（config.yml 中的 health_checks）
+// - 除声明的检查外，还会等待所有容器运行、docker-compose.yml 中的 healthcheck 显示健康
+type HealthCheck struct {
+	Service string `yaml:"service" json:"service"`                     // 服务名称
+	Type    string `yaml:"type" json:"type"`                           // http、tcp、command
+	Port    int    `yaml:"port,omitempty" json:"port,omitempty"`       // 容器内端口（http、tcp）
+	Path    string `yaml:"path,omitempty" json:"path,omitempty"`       // 请求路径（http），默认 /
+	Command string `yaml:"command,omitempty" json:"command,omitempty"` // 执行的命令（command，通过 sh -c 执行）
+	Timeout int    `yaml:"timeout,omitempty" json:"timeout,omitempty"` // 等待健康的超时时间（秒），取所有检查中的最大值，默认120
+}
+
+// Validate 检查健康检查配置，返回失败原因（为空表示通过）
+func (h HealthCheck) Validate() string {
+	switch {
+	case h.Service == "":
+		return "service is required"
+	case h.Type == HealthCheckHTTP || h.Type == HealthCheckTCP:
+		if h.Port <= 0 || h.Port > 65535 {
+			return fmt.Sprintf("%s check requires a valid port", h.Type)
+		}
+	case h.Type == HealthCheckCommand:
+		if h.Command == "" {
+			return "command check requires command"
+		}
+	default:
+		return fmt.Sprintf("unsupported type %q (supported: %s)", h.Type, strings.Join(HealthCheckTypes, ", "))
+	}
+	if h.Timeout < 0 {
+		return "timeout must be positive"
+	}
+	return ""
+}
+
+// String 健康检查的显示内容，例如 http web:8080/health
+func (h HealthCheck) String() string {
+	switch h.Type {
+	case HealthCheckHTTP:
+		return fmt.Sprintf("http %s:%d%s", h.Service, h.Port, h.httpPath())
+	case HealthCheckTCP:
+		return fmt.Sprintf("tcp %s:%d", h.Service, h.Port)
+	default:
+		return fmt.Sprintf("command %s: %s", h.Service, h.Command)
+	}
+}
+
+func (h HealthCheck) httpPath() string {
+	if h.Path == "" {
+		return "/"
+	}
+	return "/" + strings.TrimPrefix(h.Path, "/")
+}
+
+// probe 执行一次检查，失败时返回错误（包含命令输出或响应状态）
+func (h HealthCheck) probe(ctx context.Context, appId string, hosts map[string]string) error {
+	host := hosts[h.Service]
+	if host == "" {
+		return fmt.Errorf("service %s not found", h.Service)
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	switch h.Type {
+	case HealthCheckHTTP:
+		url := fmt.Sprintf("http://%s%s", net.JoinHostPort(host, strconv.Itoa(h.Port)), h.httpPath())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return fmt.Errorf("GET %s: %s %s", url, resp.Status, strings.TrimSpace(string(body)))
+		}
+		return nil
+	case HealthCheckTCP:
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(h.Port)))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case HealthCheckCommand:
+		cmd := exec.CommandContext(ctx, "docker", "compose", "exec", "-T", h.Service, "sh", "-c", h.Command)
+		cmd.Dir = filepath.Join(global.WorkDir, "config", appId)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			if out := strings.TrimSpace(string(output)); out != "" {
+				return fmt.Errorf("%v: %s", err, out)
+			}
+			return err
+		}
+		return nil
+	}
+	return errors.New(h.Validate())
+}
+
+// composeServiceHosts 应用各服务在网络中的主机名（容器名称）
+func composeServiceHosts(appId string) map[string]string {
+	hosts := map[string]string{}
+	data, err := os.ReadFile(filepath.Join(global.WorkDir, "config", appId, "docker-compose.yml"))
+	if err != nil {
+		return hosts
+	}
+	composeMap := make(map[string]interface{})
+	if yaml.Unmarshal(data, &composeMap) != nil {
+		return hosts
+	}
+	project, _ := composeMap["name"].(string)
+	services, _ := composeMap["services"].(map[string]interface{})
+	for name, service := range services {
+		hosts[name] = project + "-" + name + "-1"
+		if serviceMap, ok := service.(map[string]interface{}); ok {
+			if containerName, ok := serviceMap["container_name"].(string); ok && containerName != "" {
+				hosts[name] = containerName
+			}
+		}
+	}
+	return hosts
+}
+
+// composeNotReady 未就绪的容器（异常退出的容器返回错误）
+func composeNotReady(ctx context.Context, appId string) ([]string, error) {
+	containers, err := composeContainers(ctx, appId)
+	if err != nil {
+		return []string{err.Error()}, nil
+	}
+	if len(containers) == 0 {
+		return []string{"no containers"}, nil
+	}
+	pending := []string{}
+	for _, container := range containers {
+		switch {
+		case container.State == "exited" && container.ExitCode != 0:
+			return nil, fmt.Errorf("%s exited with code %d", container.Name, container.ExitCode)
+		case container.State == "exited":
+			// 正常退出的一次性容器
+		case container.State != "running" || (container.Health != "" && container.Health != "healthy"):
+			pending = append(pending, fmt.Sprintf("%s (%s)", container.Name, strings.TrimSpace(container.State+" "+container.Health)))
+		}
+	}
+	return pending, nil
+}
+
+// waitAppHealthy 等待应用健康：所有容器运行中（正常退出的一次性容器除外）、compose 健康检查通过、config.yml 中的健康检查通过
+// - 容器异常退出时立即返回错误，超时返回最后一次未通过的检查及输出
+func waitAppHealthy(ctx context.Context, appId string) error {
+	checks := []HealthCheck{}
+	if app, err := NewApp(appId); err == nil {
+		checks = app.HealthChecks
+	}
+	timeout := 0
+	for _, check := range checks {
+		if check.Timeout > timeout {
+			timeout = check.Timeout
+		}
+	}
+	if timeout == 0 {
+		timeout = healthDefaultTimeout
+	}
+
+	hosts := composeServiceHosts(appId)
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+	for {
+		pending, err := composeNotReady(ctx, appId)
+		if err != nil {
+			return errors.New(i18n.T("HealthCheckFailed", err.Error()))
+		}
+		if len(pending) == 0 {
+			for _, check := range checks {
+				if err := check.probe(ctx, appId, hosts); err != nil {
+					pending = append(pending, check.String()+": "+err.Error())
+					break
+				}
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New(i18n.T("HealthCheckFailed", fmt.Sprintf("timeout after %ds: %s", timeout, strings.Join(pending, ", "))))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(healthInterval):
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"appstore/server/global"
+	"appstore/server/i18n"
+)
+
+// 版本钩子阶段
+const (
+	HookPreUpgrade   = "pre_upgrade"   // 升级前（新版本容器启动前）
+	HookPostUpgrade  = "post_upgrade"  // 升级后（新版本容器启动后）
+	HookPreUninstall = "pre_uninstall" // 卸载前（容器停止前）
+)
+
+// hookDefaultTimeout 钩子默认超时时间（秒）
+const hookDefaultTimeout = 300
+
+// VersionHook 版本钩子
+type VersionHook struct {
+	Service     string `yaml:"service" json:"service"`           // 执行命令的服务名称
+	Run         bool   `yaml:"run" json:"run"`                   // true：按服务定义启动一次性容器执行，false：在运行中的服务容器内执行
+	Command     string `yaml:"command" json:"command"`           // 执行的命令（通过 sh -c 执行）
+	Timeout     int    `yaml:"timeout" json:"timeout"`           // 超时时间（秒），默认300
+	IgnoreError bool   `yaml:"ignore_error" json:"ignore_error"` // 失败时是否继续
+}
+
+// VersionHooks 版本钩子配置（版本目录中的 hooks.yml）
+type VersionHooks struct {
+	PreUpgrade   []VersionHook `yaml:"pre_upgrade" json:"pre_upgrade"`
+	PostUpgrade  []VersionHook `yaml:"post_upgrade" json:"post_upgrade"`
+	PreUninstall []VersionHook `yaml:"pre_uninstall" json:"pre_uninstall"`
+}
+
+// GetVersionHooks 读取版本的钩子配置，没有 hooks.yml 时返回空配置
+func GetVersionHooks(appId, version string) (*VersionHooks, error) {
+	hooks := &VersionHooks{}
+	data, err := os.ReadFile(filepath.Join(global.WorkDir, "apps", appId, version, "hooks.yml"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return hooks, nil
+		}
+		return nil, err
+	}
+	return ParseVersionHooks(data)
+}
+
+// ParseVersionHooks 严格解析钩子配置，不允许未知的配置项
+func ParseVersionHooks(data []byte) (*VersionHooks, error) {
+	hooks := &VersionHooks{}
+	if err := decodeStrict(data, hooks); err != nil {
+		return nil, err
+	}
+	for _, stage := range []string{HookPreUpgrade, HookPostUpgrade, HookPreUninstall} {
+		for _, hook := range hooks.Stage(stage) {
+			if hook.Service == "" || hook.Command == "" {
+				return nil, fmt.Errorf("%s: hook service and command are required", stage)
+			}
+		}
+	}
+	return hooks, nil
+}
+
+// Stage 获取指定阶段的钩子
+func (h *VersionHooks) Stage(stage string) []VersionHook {
+	switch stage {
+	case HookPreUpgrade:
+		return h.PreUpgrade
+	case HookPostUpgrade:
+		return h.PostUpgrade
+	case HookPreUninstall:
+		return h.PreUninstall
+	}
+	return nil
+}
+
+// RunVersionHooks 依次执行版本指定阶段的钩子，输出写入应用日志
+// - env 会作为环境变量传入容器（例如 FROM_VERSION、TO_VERSION）
+// - 钩子失败（或超时）时返回错误，设置了 ignore_error 的钩子除外
+func RunVersionHooks(ctx context.Context, appId, version, stage string, env map[string]string) error {
+	hooks, err := GetVersionHooks(appId, version)
+	if err != nil {
+		return errors.New(i18n.T("ParseHooksFailed", err))
+	}
+
+	for i, hook := range hooks.Stage(stage) {
+		timeout := hook.Timeout
+		if timeout <= 0 {
+			timeout = hookDefaultTimeout
+		}
+
+		// 组装命令参数
+		args := []string{"compose"}
+		if hook.Run {
+			args = append(args, "run", "--rm", "--no-deps", "-T")
+		} else {
+			args = append(args, "exec", "-T")
+		}
+		keys := make([]string, 0, len(env))
+		for key := range env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			args = append(args, "-e", key+"="+env[key])
+		}
+		args = append(args, hook.Service, "sh", "-c", hook.Command)
+
+		AppLogInfo(appId, fmt.Sprintf("[Hook] %s #%d (%s) starting: %s", stage, i+1, hook.Service, hook.Command))
+		hookCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		err := runComposeCommand(hookCtx, appId, args...)
+		if errors.Is(hookCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timeout after %d seconds", timeout)
+		}
+		cancel()
+
+		if err != nil {
+			if hook.IgnoreError {
+				AppLogWarn(appId, fmt.Sprintf("[Hook] %s #%d failed (ignored): %v", stage, i+1, err))
+				continue
+			}
+			AppLogError(appId, fmt.Sprintf("[Hook] %s #%d failed: %v", stage, i+1, err))
+			return fmt.Errorf("%s #%d: %w", stage, i+1, err)
+		}
+		AppLogInfo(appId, fmt.Sprintf("[Hook] %s #%d successful", stage, i+1))
+	}
+	return nil
+}
+
+// runComposeCommand 在应用配置目录执行docker命令，输出逐行写入应用日志
+func runComposeCommand(ctx context.Context, appId string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Dir = filepath.Join(global.WorkDir, "config", appId)
+
+	// 创建管道来捕获输出
+	stdout, _ := cmd.StdoutPipe()
+	stderr, _ := cmd.StderrPipe()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// 读取并记录输出
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			AppLogInfo(appId, scanner.Text())
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			AppLogWarn(appId, scanner.Text())
+		}
+	}()
+	wg.Wait()
+
+	return cmd.Wait()
+}
@@ -0,0 +1,335 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"appstore/server/global"
+	"appstore/server/i18n"
+	"appstore/server/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InstallApp 安装或更新应用
+// 1、处理latest版本
+// 2、检查应用状态，以及指定的版本在订阅的发布通道和允许的版本范围内
+// 3、检查是否需要先卸载
+// 4、检查版本兼容性和与已安装的其他应用的冲突
+// 5、检查依赖此应用的其他应用和此应用的依赖（缺少的依赖可先依次安装，异步）
+// 6、检查版本钩子配置
+// 7、升级时备份当前配置，用于钩子失败时回滚
+// 8、保存配置，生成docker-compose.yml和nginx配置
+// 9、执行docker-compose up命令（异步，升级时执行升级钩子）
+// 10、返回安装的版本（第一个参数不为空表示成功）
+func InstallApp(req *AppInternalInstallRequest) (string, string, error) {
+	return installApp(req, false)
+}
+
+// installApp 安装或更新应用，chained 表示由依赖安装完成后调用（此时应用仍标记为正在安装依赖）
+func installApp(req *AppInternalInstallRequest, chained bool) (string, string, error) {
+	// 处理latest版本
+	if req.Version == "latest" {
+		latestV, err := FindLatestVersion(req.AppID)
+		if err != nil {
+			return "", i18n.T("CannotDetermineLatestVersionSingle", req.AppID), err
+		}
+		req.Version = latestV
+	}
+
+	// 获取当前应用配置
+	appConfig := GetAppConfig(req.AppID)
+
+	// 判断当前状态（包括正在安装依赖）
+	if appConfig.Status == "installing" || appConfig.Status == "uninstalling" {
+		return "", i18n.T("AppIsRunning"), nil
+	}
+	if _, pending := requiresInstalling.Load(req.AppID); pending && !chained {
+		return "", i18n.T("AppIsRunning"), nil
+	}
+
+	app, err := NewApp(req.AppID)
+	if err != nil {
+		return "", i18n.T("GetAppDetailFailed"), err
+	}
+
+	// 检查指定的版本在订阅的发布通道和允许的版本范围内
+	if stderr, err := checkVersionAllowed(appConfig, req.Version); err != nil {
+		return "", stderr, err
+	}
+
+	// 检查是否需要先卸载
+	if appConfig.Status == "installed" && appConfig.InstallVersion != "" {
+		if require := findRequireUninstall(app); require != nil {
+			reason := require.Reason.(string)
+			message := i18n.T("NeedUninstallBeforeUpdateSingle", req.Version)
+			if reason == "" {
+				message = i18n.T("NeedUninstallBeforeUpdate", map[string]interface{}{
+					"version": req.Version,
+					"reason":  reason,
+				})
+			}
+			return "", message, errors.New(reason)
+		}
+	}
+
+	// 检查版本是否兼容当前 DooTask 版本和平台
+	if stderr, err := checkCompatibility(app, req.Version); err != nil {
+		return "", stderr, err
+	}
+
+	// 子域名路由需要配置基础域名，且 DooTask 的 nginx 包含应用的 server.conf
+	if app.IsSubdomainRouting() {
+		if _, err := AppHost(req.AppID); err != nil {
+			return "", err.Error(), err
+		}
+		if err := checkNginxServerInclude(); err != nil {
+			return "", err.Error(), err
+		}
+	}
+
+	// 检查nginx配置的占位符都有对应的参数
+	if _, err := renderNginxTemplate(req.AppID, req.Version, req.Params); err != nil {
+		return "", err.Error(), err
+	}
+
+	// 检查与已安装的其他应用的冲突
+	if stderr, err := checkConflicts(app, req.Version, req.Params); err != nil {
+		return "", stderr, err
+	}
+
+	// 检查依赖此应用的其他应用
+	if stderr, err := checkDependents(req.AppID, req.Version); err != nil {
+		return "", stderr, err
+	}
+
+	// 检查应用依赖，缺少的依赖先依次安装
+	steps, stderr, err := resolveRequires(req.AppID, req.InstallRequires)
+	if err != nil {
+		return "", stderr, err
+	}
+	if len(steps) > 0 {
+		// 每个应用同一时间只启动一个依赖安装任务
+		if _, loaded := requiresInstalling.LoadOrStore(req.AppID, true); loaded {
+			return "", i18n.T("AppIsRunning"), nil
+		}
+		go installRequires(*req, steps)
+		return req.Version, "", nil
+	}
+
+	// 检查版本钩子配置
+	if _, err := GetVersionHooks(req.AppID, req.Version); err != nil {
+		return "", i18n.T("ParseHooksFailed", err), err
+	}
+
+	// 创建配置目录
+	configDir := filepath.Join(global.WorkDir, "config", req.AppID)
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return "", i18n.T("CreateConfigDirFailed"), err
+	}
+
+	// 升级时备份当前配置
+	removeRollback(req.AppID)
+	if appConfig.Status == "installed" && appConfig.InstallVersion != "" && appConfig.InstallVersion != req.Version {
+		if err := createRollback(req.AppID); err != nil {
+			return "", i18n.T("CreateRollbackFailed"), err
+		}
+	}
+
+	// 蓝绿升级时新版本使用另一个compose项目（不支持时使用普通升级）
+	if utils.IsDirExists(rollbackDir(req.AppID)) && app.IsBlueGreen(req.Version) {
+		if composeMap, err := renderDockerComposeTemplate(req.AppID, req.Version, req.Params); err != nil {
+			return "", i18n.T("GenerateDockerComposeFailed"), err
+		} else if reason := blueGreenUnsupported(composeMap); reason != "" {
+			AppLogWarn(req.AppID, "blue-green upgrade skipped: "+reason)
+		} else {
+			appConfig.Project = nextComposeProject(req.AppID, appConfig)
+		}
+	}
+
+	// 更新配置
+	appConfig.InstallVersion = req.Version
+	appConfig.Params = req.Params
+	appConfig.Resources = req.Resources
+
+	// 保存配置到文件
+	if err := SaveAppConfig(req.AppID, appConfig); err != nil {
+		return "", i18n.T("SaveConfigFailed"), err
+	}
+
+	// 生成docker-compose.yml文件
+	if err := GenerateDockerCompose(req.AppID, req.Version, appConfig); err != nil {
+		return "", i18n.T("GenerateDockerComposeFailed"), err
+	}
+
+	// 生成nginx配置文件
+	if err := GenerateNginxConfig(req.AppID, req.Version, appConfig); err != nil {
+		return "", i18n.T("GenerateNginxConfigFailed"), err
+	}
+
+	// 执行docker-compose up命令
+	if err := RunDockerCompose(req.AppID, "up", false); err != nil {
+		return "", i18n.T("StartAppFailed"), err
+	}
+
+	return req.Version, "", nil
+}
+
+// UninstallApp 卸载应用
+// 1、检查应用状态（只能卸载已安装或安装失败的应用）
+// 2、检查依赖此应用的其他应用，存在必需依赖时需要 force 才能卸载
+// 3、执行docker-compose down命令（异步，先执行卸载钩子并删除nginx配置，force 或安装失败时卸载钩子失败也继续）
+// 4、返回卸载的版本（第一个参数不为空表示成功）
+func UninstallApp(appId string, force bool) (string, string, error) {
+	// 判断当前状态
+	appConfig := GetAppConfig(appId)
+	if appConfig.Status != "installed" && appConfig.Status != "error" {
+		return "", i18n.T("AppNotInstalled"), nil
+	}
+
+	// 检查依赖此应用的其他应用
+	required, optional := FindDependents(appId)
+	if len(required) > 0 {
+		if !force {
+			return "", i18n.T("AppRequiredByOthers", strings.Join(required, ", ")), nil
+		}
+		AppLogWarn(appId, "force uninstall, required by: "+strings.Join(required, ", "))
+	}
+	if len(optional) > 0 {
+		AppLogWarn(appId, "uninstall, optionally required by: "+strings.Join(optional, ", "))
+	}
+
+	// 执行docker-compose down命令（安装失败的应用容器可能无法执行卸载钩子）
+	if err := RunDockerCompose(appId, "down", force || appConfig.Status == "error"); err != nil {
+		return "", i18n.T("UninstallAppFailed"), err
+	}
+
+	return appConfig.InstallVersion, "", nil
+}
+
+// checkVersionAllowed 检查指定的版本是否在订阅的发布通道内，已安装时是否在允许的版本范围内（重新安装已安装的版本不检查）
+func checkVersionAllowed(appConfig *AppConfig, version string) (string, error) {
+	installed := appConfig.Status != "not_installed" && appConfig.InstallVersion != ""
+	if installed && version == appConfig.InstallVersion {
+		return "", nil
+	}
+	if len(appConfig.ChannelVersions([]string{version})) == 0 {
+		channel := appConfig.Channel
+		if channel == "" {
+			channel = VersionChannelStable
+		}
+		message := i18n.T("VersionNotInChannel", map[string]interface{}{
+			"version": version,
+			"channel": channel,
+		})
+		return message, errors.New(message)
+	}
+	if installed && len(appConfig.AllowedVersions([]string{version})) == 0 {
+		message := i18n.T("VersionOutOfRange", map[string]interface{}{
+			"version": version,
+			"range":   appConfig.VersionRange,
+		})
+		return message, errors.New(message)
+	}
+	return "", nil
+}
+
+// findRequireUninstall 查找已安装版本命中的“需要先卸载”要求，没有则返回nil
+func findRequireUninstall(app *App) *RequireUninstall {
+	if app.Config == nil || app.Config.InstallVersion == "" {
+		return nil
+	}
+	for _, require := range app.RequireUninstalls {
+		if utils.CheckVersionRequirement(app.Config.InstallVersion, require.Operator, require.Version) {
+			return &require
+		}
+	}
+	return nil
+}
+
+// rollbackFiles 升级前需要备份的配置文件
+var rollbackFiles = []string{"config.yml", "docker-compose.yml", "nginx.conf", "server.conf"}
+
+// rollbackDir 升级回滚备份目录
+func rollbackDir(appId string) string {
+	return filepath.Join(global.WorkDir, "config", appId, "rollback")
+}
+
+// createRollback 备份当前配置文件
+func createRollback(appId string) error {
+	dir := rollbackDir(appId)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, name := range rollbackFiles {
+		src := filepath.Join(global.WorkDir, "config", appId, name)
+		if utils.IsFileExists(src) {
+			if err := utils.CopyFile(src, filepath.Join(dir, name), true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getRollbackConfig 获取升级前的应用配置，不是升级时返回nil
+func getRollbackConfig(appId string) *AppConfig {
+	data, err := os.ReadFile(filepath.Join(rollbackDir(appId), "config.yml"))
+	if err != nil {
+		return nil
+	}
+	appConfig := &AppConfig{}
+	if err := yaml.Unmarshal(data, appConfig); err != nil || appConfig.InstallVersion == "" {
+		return nil
+	}
+	return appConfig
+}
+
+// removeRollback 删除回滚备份
+func removeRollback(appId string) {
+	_ = os.RemoveAll(rollbackDir(appId))
+}
+
+// restoreRollback 回滚到升级前的配置，restart 为 true 时使用旧配置重新启动容器，返回应用状态
+func restoreRollback(ctx context.Context, appId string, restart bool) string {
+	previous := getRollbackConfig(appId)
+	if previous == nil {
+		return "error"
+	}
+	AppLogInfo(appId, "rollback to "+previous.InstallVersion+" starting...")
+
+	// 维护期间暂存的配置由备份覆盖
+	stopMaintenance(appId)
+
+	// 恢复配置文件
+	for _, name := range rollbackFiles {
+		src := filepath.Join(rollbackDir(appId), name)
+		dest := filepath.Join(global.WorkDir, "config", appId, name)
+		if utils.IsFileExists(src) {
+			if err := utils.CopyFile(src, dest, true); err != nil {
+				AppLogError(appId, "rollback failed: "+err.Error())
+				return "error"
+			}
+		} else {
+			_ = os.Remove(dest)
+		}
+	}
+
+	// 使用旧配置重新启动容器
+	if restart {
+		if err := runComposeCommand(ctx, appId, "compose", "up", "-d", "--remove-orphans"); err != nil {
+			AppLogError(appId, "rollback failed: "+err.Error())
+			return "error"
+		}
+		if out, err := ReloadNginx(appId, 3); err != nil {
+			AppLogError(appId, "nginx reload failed: "+out+" "+err.Error())
+			return "error"
+		}
+	}
+
+	AppLogInfo(appId, "rollback to "+previous.InstallVersion+" successful")
+	return "installed"
+}
@@ -0,0 +1,403 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"appstore/server/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 检查问题级别
+const (
+	LintLevelError   = "error"
+	LintLevelWarning = "warning"
+)
+
+// LintIssue 应用目录检查发现的问题
+type LintIssue struct {
+	Level   string `json:"level"`   // error, warning
+	Code    string `json:"code"`    // 问题代码，例如 unknown_key
+	File    string `json:"file"`    // 相对于应用目录的文件路径
+	Message string `json:"message"` // 问题说明
+}
+
+// LintResult 应用目录检查结果
+type LintResult struct {
+	AppID    string      `json:"app_id"`
+	Dir      string      `json:"dir"`
+	Valid    bool        `json:"valid"` // 没有错误（可以有警告）
+	Versions []string    `json:"versions"`
+	Errors   int         `json:"errors"`
+	Warnings int         `json:"warnings"`
+	Issues   []LintIssue `json:"issues"`
+}
+
+// add 添加问题
+func (r *LintResult) add(level, code, file, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, LintIssue{
+		Level:   level,
+		Code:    code,
+		File:    file,
+		Message: fmt.Sprintf(format, args...),
+	})
+	if level == LintLevelError {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+}
+
+var (
+	lintPlaceholderRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)([:?+-][^}]*)?\}`)
+	lintFieldNameRegex   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	lintVersionLikeRegex = regexp.MustCompile(`^v?\d`)
+)
+
+// lintBuiltinPlaceholders 安装时自动替换的占位符，以及 DooTask 环境变量中提供的占位符
+var lintBuiltinPlaceholders = []string{
+	"HOST_PWD", "PUBLIC_PATH",
+	"APP_ID", "APP_KEY", "TIMEZONE",
+	"DB_HOST", "DB_PORT", "DB_DATABASE", "DB_USERNAME", "DB_PASSWORD", "DB_PREFIX",
+	"REDIS_HOST", "REDIS_PORT",
+}
+
+// LintApp 检查应用目录是否符合应用商店规范
+// - config.yml：严格解析（不允许未知配置项）、必填项、字段、菜单、依赖、冲突、兼容性
+// - 每个版本：docker-compose.yml、nginx.conf、hooks.yml，以及未定义的占位符
+// - 图标和 README
+func LintApp(appDir string) *LintResult {
+	appDir = filepath.Clean(appDir)
+	appId := filepath.Base(appDir)
+	if absDir, err := filepath.Abs(appDir); err == nil {
+		appId = filepath.Base(absDir) // 例如 lint . 时使用当前目录名
+	}
+	result := &LintResult{
+		AppID:    appId,
+		Dir:      appDir,
+		Versions: []string{},
+		Issues:   []LintIssue{},
+	}
+	defer func() {
+		result.Valid = result.Errors == 0
+	}()
+
+	if !utils.IsDirExists(appDir) {
+		result.add(LintLevelError, "dir_not_found", "", "app directory not found: %s", appDir)
+		return result
+	}
+
+	manifest := lintManifest(appDir, result)
+
+	// 版本目录
+	entries, _ := os.ReadDir(appDir)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if !utils.IsValidVersion(name) {
+			if lintVersionLikeRegex.MatchString(name) {
+				result.add(LintLevelWarning, "version_dir_invalid", name, "directory %q looks like a version but is not a valid SemVer version, it will be ignored", name)
+			}
+			continue
+		}
+		if !utils.IsFileExists(filepath.Join(appDir, name, "docker-compose.yml")) {
+			result.add(LintLevelError, "compose_missing", name, "version %s has no docker-compose.yml, it will be ignored", name)
+			continue
+		}
+		result.Versions = append(result.Versions, name)
+		lintVersion(appDir, name, manifest, result)
+	}
+	slices.SortFunc(result.Versions, func(a, b string) int {
+		return utils.CompareVersions(b, a)
+	})
+	if len(result.Versions) == 0 {
+		result.add(LintLevelError, "no_versions", "", "no valid version directory with docker-compose.yml found")
+	}
+
+	// 按版本配置的内容需要对应存在的版本
+	if manifest != nil {
+		for version := range manifest.Compatibility {
+			if !slices.Contains(result.Versions, version) {
+				result.add(LintLevelWarning, "version_unknown", "config.yml", "compatibility: version %s does not exist", version)
+			}
+		}
+		for version := range manifest.Changelog {
+			if !slices.Contains(result.Versions, version) {
+				result.add(LintLevelWarning, "version_unknown", "config.yml", "changelog: version %s does not exist", version)
+			}
+		}
+	}
+
+	// 图标和 README
+	if !slices.ContainsFunc([]string{"logo.svg", "logo.png", "icon.svg", "icon.png"}, func(name string) bool {
+		return utils.IsFileExists(filepath.Join(appDir, name))
+	}) {
+		result.add(LintLevelWarning, "icon_missing", "", "no logo.svg, logo.png, icon.svg or icon.png found, the default icon will be used")
+	}
+	if readLocalizedFile(appDir, "README") == "" {
+		result.add(LintLevelWarning, "readme_missing", "", "no README.md found")
+	}
+
+	return result
+}
+
+// lintManifest 检查 config.yml，解析失败时返回nil
+func lintManifest(appDir string, result *LintResult) *AppManifest {
+	const file = "config.yml"
+	data, err := os.ReadFile(filepath.Join(appDir, file))
+	if err != nil {
+		result.add(LintLevelError, "config_missing", file, "config.yml not found")
+		return nil
+	}
+
+	manifest, err := DecodeManifest(data)
+	if err != nil {
+		var typeErr *yaml.TypeError
+		for _, message := range yamlErrorMessages(err) {
+			code := "config_invalid"
+			if strings.Contains(message, "unknown key") {
+				code = "unknown_key"
+			} else if errors.As(err, &typeErr) {
+				code = "invalid_value"
+			}
+			result.add(LintLevelError, code, file, "%s", message)
+		}
+		// 类型错误时仍然尽量解析，继续检查其他内容
+		manifest = &AppManifest{}
+		if yaml.Unmarshal(data, manifest) != nil {
+			return nil
+		}
+	}
+
+	// 基本信息
+	if getLocalizedValue(manifest.Name, "en") == "" {
+		result.add(LintLevelError, "name_missing", file, "name is required")
+	}
+	if getLocalizedValue(manifest.Description, "en") == "" {
+		result.add(LintLevelWarning, "description_missing", file, "description is recommended")
+	}
+
+	// 字段
+	fieldNames := []string{}
+	for i, field := range manifest.Fields {
+		switch {
+		case field.Name == "":
+			result.add(LintLevelError, "field_name_missing", file, "fields[%d]: name is required", i)
+		case !lintFieldNameRegex.MatchString(field.Name):
+			result.add(LintLevelError, "field_name_invalid", file, "fields[%d]: name %q must contain only letters, digits and underscores", i, field.Name)
+		case slices.Contains(fieldNames, field.Name):
+			result.add(LintLevelError, "field_name_duplicate", file, "fields[%d]: duplicate name %q", i, field.Name)
+		}
+		fieldNames = append(fieldNames, field.Name)
+		if field.Type != "" && !slices.Contains(FieldTypes, field.Type) {
+			result.add(LintLevelError, "field_type_invalid", file, "fields[%d]: unsupported type %q (supported: %s)", i, field.Type, strings.Join(FieldTypes, ", "))
+		}
+		if field.Type == "select" && len(field.Options) == 0 {
+			result.add(LintLevelError, "field_options_missing", file, "fields[%d]: select field requires options", i)
+		}
+	}
+
+	// 需要先卸载的版本
+	for i, require := range manifest.RequireUninstalls {
+		_, version := utils.ParseVersionOperator(require.Version)
+		if require.Version == "" || !utils.IsValidVersion(strings.TrimSpace(version)) {
+			result.add(LintLevelError, "require_uninstall_invalid", file, "require_uninstalls[%d]: invalid version %q", i, require.Version)
+		}
+	}
+
+	// 依赖和冲突
+	appId := filepath.Base(appDir)
+	for i, require := range manifest.Requires {
+		lintAppReference(result, fmt.Sprintf("requires[%d]", i), appId, require.App, require.Version)
+	}
+	for i, conflict := range manifest.Conflicts {
+		lintAppReference(result, fmt.Sprintf("conflicts[%d]", i), appId, conflict.App, conflict.Version)
+	}
+
+	// 兼容性
+	lintCompatibility(result, "", VersionCompatibility{DooTaskVersion: manifest.DooTaskVersion, Platforms: manifest.Platforms})
+	for version, compatibility := range manifest.Compatibility {
+		lintCompatibility(result, fmt.Sprintf("compatibility.%s: ", version), compatibility)
+	}
+
+	// 蓝绿升级
+	if manifest.BlueGreen != "" {
+		if err := utils.ValidateVersionConstraint(manifest.BlueGreen); err != nil {
+			result.add(LintLevelError, "version_range_invalid", file, "invalid blue_green %q: %v", manifest.BlueGreen, err)
+		}
+	}
+
+	// 健康检查
+	for i, check := range manifest.HealthChecks {
+		if reason := check.Validate(); reason != "" {
+			result.add(LintLevelError, "health_check_invalid", file, "health_checks[%d]: %s", i, reason)
+		}
+	}
+
+	// 路由模式
+	if manifest.Routing != "" && !slices.Contains(RoutingModes, manifest.Routing) {
+		result.add(LintLevelError, "routing_invalid", file, "unsupported routing %q (supported: %s)", manifest.Routing, strings.Join(RoutingModes, ", "))
+	}
+	if manifest.Routing == RoutingSubdomain {
+		if _, ok := appHostLabel(result.AppID); !ok {
+			result.add(LintLevelError, "routing_host_invalid", file, "app id %q cannot be used as a subdomain", result.AppID)
+		}
+	}
+
+	// 菜单
+	for i, menu := range manifest.MenuItems {
+		if !slices.Contains(MenuLocations, menu.Location) {
+			result.add(LintLevelError, "menu_location_invalid", file, "menu_items[%d]: unsupported location %q (supported: %s)", i, menu.Location, strings.Join(MenuLocations, ", "))
+		}
+		if menu.URL == "" {
+			result.add(LintLevelError, "menu_url_missing", file, "menu_items[%d]: url is required", i)
+		}
+		if menu.Icon != "" {
+			iconPath := filepath.Clean(menu.Icon)
+			if strings.Contains(iconPath, "..") || filepath.IsAbs(iconPath) || !utils.IsFileExists(filepath.Join(appDir, iconPath)) {
+				result.add(LintLevelError, "menu_icon_missing", file, "menu_items[%d]: icon %q not found in the app directory", i, menu.Icon)
+			}
+		}
+	}
+
+	return manifest
+}
+
+// lintAppReference 检查依赖或冲突的应用和版本范围
+func lintAppReference(result *LintResult, path, appId, refAppId, versionRange string) {
+	if refAppId == "" {
+		result.add(LintLevelError, "app_missing", "config.yml", "%s: app is required", path)
+	} else if refAppId == appId {
+		result.add(LintLevelError, "app_self", "config.yml", "%s: app cannot reference itself", path)
+	}
+	if versionRange != "" {
+		if err := utils.ValidateVersionConstraint(versionRange); err != nil {
+			result.add(LintLevelError, "version_range_invalid", "config.yml", "%s: invalid version range %q: %v", path, versionRange, err)
+		}
+	}
+}
+
+// lintCompatibility 检查兼容性要求
+func lintCompatibility(result *LintResult, prefix string, compatibility VersionCompatibility) {
+	if compatibility.DooTaskVersion != "" {
+		if err := utils.ValidateVersionConstraint(compatibility.DooTaskVersion); err != nil {
+			result.add(LintLevelError, "version_range_invalid", "config.yml", "%sinvalid dootask_version %q: %v", prefix, compatibility.DooTaskVersion, err)
+		}
+	}
+	for _, platform := range compatibility.Platforms {
+		parts := strings.Split(platform, "/")
+		if platform == "" || len(parts) > 3 || slices.Contains(parts, "") {
+			result.add(LintLevelError, "platform_invalid", "config.yml", "%sinvalid platform %q (e.g. linux/amd64)", prefix, platform)
+		}
+	}
+}
+
+// lintVersion 检查版本目录
+func lintVersion(appDir, version string, manifest *AppManifest, result *LintResult) {
+	fieldNames := []string{}
+	if manifest != nil {
+		for _, field := range manifest.Fields {
+			fieldNames = append(fieldNames, field.Name)
+		}
+	}
+
+	// docker-compose.yml
+	composeFile := filepath.Join(version, "docker-compose.yml")
+	services := []string{}
+	if data, err := os.ReadFile(filepath.Join(appDir, composeFile)); err == nil {
+		composeMap := make(map[string]interface{})
+		if err := yaml.Unmarshal(data, &composeMap); err != nil {
+			result.add(LintLevelError, "compose_invalid", composeFile, "%s", err.Error())
+		} else if serviceMap, ok := composeMap["services"].(map[string]interface{}); !ok || len(serviceMap) == 0 {
+			result.add(LintLevelError, "compose_no_services", composeFile, "services is required")
+		} else {
+			for name := range serviceMap {
+				services = append(services, name)
+				if slices.Contains(ProtectedNames, name) {
+					result.add(LintLevelError, "service_protected", composeFile, "service name %q is reserved", name)
+				}
+			}
+			if manifest != nil {
+				for i, check := range manifest.HealthChecks {
+					if _, ok := serviceMap[check.Service]; check.Service != "" && !ok {
+						result.add(LintLevelError, "health_check_service_missing", composeFile, "health_checks[%d]: service %q not found", i, check.Service)
+					}
+				}
+			}
+			if manifest != nil && manifest.BlueGreen != "" && utils.CheckVersionConstraint(version, manifest.BlueGreen) {
+				if reason := blueGreenUnsupported(composeMap); reason != "" {
+					result.add(LintLevelError, "blue_green_unsupported", composeFile, "%s, which is not supported by blue-green upgrades", reason)
+				}
+			}
+		}
+		for _, matches := range lintPlaceholderRegex.FindAllStringSubmatch(string(data), -1) {
+			name := matches[1]
+			if matches[2] == "" && !slices.Contains(fieldNames, name) && !slices.Contains(lintBuiltinPlaceholders, name) {
+				result.add(LintLevelWarning, "placeholder_undefined", composeFile, "placeholder ${%s} is not defined in fields", name)
+			}
+		}
+	}
+
+	// nginx.conf
+	nginxFile := filepath.Join(version, "nginx.conf")
+	if data, err := os.ReadFile(filepath.Join(appDir, nginxFile)); err == nil {
+		content := regexp.MustCompile(`(?m)#.*$`).ReplaceAllString(string(data), "")
+		if strings.Count(content, "{") != strings.Count(content, "}") {
+			result.add(LintLevelError, "nginx_unbalanced", nginxFile, "unbalanced braces")
+		}
+		// 使用字段默认值替换参数后检查 location
+		params := map[string]interface{}{}
+		if manifest != nil {
+			for _, field := range manifest.Fields {
+				if field.Default != nil {
+					params[field.Name] = field.Default
+				}
+			}
+		}
+		locations := parseNginxLocations(renderTemplateParams(content, params))
+		if len(locations) == 0 {
+			result.add(LintLevelWarning, "nginx_no_location", nginxFile, "no location block found")
+		}
+		for _, matches := range lintPlaceholderRegex.FindAllStringSubmatch(content, -1) {
+			if name := matches[1]; matches[2] == "" && strings.ToUpper(name) == name && !slices.Contains(fieldNames, name) {
+				result.add(LintLevelError, "placeholder_undefined", nginxFile, "placeholder ${%s} is not defined in fields (only fields are replaced in nginx.conf, use ${%s:-default} for a default value)", name, name)
+			}
+		}
+		// 子域名路由使用独立的 server 块，不会与 DooTask 的路由冲突
+		for _, location := range locations {
+			if manifest != nil && manifest.Routing == RoutingSubdomain {
+				break
+			}
+			if core, ok := location.coreLocation(); ok {
+				result.add(LintLevelError, "location_reserved", nginxFile, "location %s overlaps DooTask core route %s", location.String(), core.String())
+			}
+		}
+	}
+
+	// hooks.yml
+	hooksFile := filepath.Join(version, "hooks.yml")
+	if data, err := os.ReadFile(filepath.Join(appDir, hooksFile)); err == nil {
+		hooks, err := ParseVersionHooks(data)
+		if err != nil {
+			for _, message := range yamlErrorMessages(err) {
+				result.add(LintLevelError, "hooks_invalid", hooksFile, "%s", message)
+			}
+		} else {
+			for _, stage := range []string{HookPreUpgrade, HookPostUpgrade, HookPreUninstall} {
+				for i, hook := range hooks.Stage(stage) {
+					if len(services) > 0 && !slices.Contains(services, hook.Service) {
+						result.add(LintLevelError, "hook_service_unknown", hooksFile, "%s[%d]: service %q not found in docker-compose.yml", stage, i, hook.Service)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"appstore/server/global"
+	"appstore/server/i18n"
+	"appstore/server/utils"
+)
+
+// ErrWorkDirLocked 工作目录锁已被其他进程持有
+var ErrWorkDirLocked = errors.New("work dir is locked by another process")
+
+// workDirLockFile 工作目录锁文件，服务运行期间、命令行安装或卸载期间持有
+func workDirLockFile() string {
+	return filepath.Join(global.WorkDir, "config", ".lock")
+}
+
+// LockWorkDir 获取工作目录锁，同一工作目录同一时间只有一个进程安装或卸载应用，返回释放锁的函数（进程退出时自动释放）
+// - wait 为 false 时锁已被其他进程持有则返回 ErrWorkDirLocked
+// - 获取后将其他进程中断的安装、卸载（状态停留在 installing、uninstalling）标记为错误
+func LockWorkDir(wait bool) (func(), error) {
+	lockFile := workDirLockFile()
+	if err := os.MkdirAll(filepath.Dir(lockFile), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(lockFile, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFileExclusive(file, wait); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// 持有锁时不会有其他进程正在安装或卸载，停留在 installing、uninstalling 的应用已被中断
+	MarkInterruptedApps()
+
+	return func() {
+		_ = unlockFile(file)
+		file.Close()
+	}, nil
+}
+
+// MarkInterruptedApps 将所有正在安装或卸载的应用标记为中断（持有工作目录锁的进程退出前或获取锁后调用）
+func MarkInterruptedApps() {
+	appIds, _ := utils.GetSubDirs(filepath.Join(global.WorkDir, "config"))
+	for _, appId := range appIds {
+		markAppInterrupted(appId)
+	}
+}
+
+// markAppInterrupted 将中断的安装或卸载（进程退出）标记为错误，之后可以重新安装或卸载
+// - 恢复维护页面之前的nginx配置，删除待生效的nginx配置
+func markAppInterrupted(appId string) {
+	appConfig := GetAppConfig(appId)
+	if appConfig.Status != "installing" && appConfig.Status != "uninstalling" {
+		return
+	}
+	AppLogWarn(appId, fmt.Sprintf("%s interrupted, marked as error", appConfig.Status))
+	appConfig.Status = "error"
+	appConfig.Error = i18n.T("AppOperationInterrupted")
+	if err := SaveAppConfig(appId, appConfig); err != nil {
+		AppLogError(appId, "Failed to save app status: "+err.Error())
+	}
+	removeStagedNginxConfig(appId)
+	if stopMaintenance(appId) {
+		if out, err := ReloadNginx(appId, 1); err != nil {
+			AppLogError(appId, "nginx reload failed: "+out+" "+err.Error())
+		}
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+
+package models
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFileExclusive 对文件加排他锁，wait 为 false 时锁已被持有则返回 ErrWorkDirLocked
+func lockFileExclusive(file *os.File, wait bool) error {
+	how := syscall.LOCK_EX
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+	if err := syscall.Flock(int(file.Fd()), how); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return ErrWorkDirLocked
+		}
+		return err
+	}
+	return nil
+}
+
+// unlockFile 释放文件锁
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package models
+
+import "os"
+
+// lockFileExclusive Windows 不支持 flock，不加锁
+func lockFileExclusive(*os.File, bool) error {
+	return nil
+}
+
+// unlockFile Windows 不支持 flock，不加锁
+func unlockFile(*os.File) error {
+	return nil
+}
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"appstore/server/global"
+	"appstore/server/i18n"
+	"appstore/server/utils"
+)
+
+// maintenanceSuffix 维护期间暂存的应用nginx配置文件后缀
+const maintenanceSuffix = ".maintenance"
+
+// maintenanceRetryAfter 维护页面建议的重试时间（秒），页面按此间隔自动刷新
+const maintenanceRetryAfter = 10
+
+// maintenanceLanguages 维护页面的语言及匹配 Accept-Language 的正则（按顺序匹配，都不匹配时使用英文）
+// - 繁体中文（zh-TW、zh-HK、zh-MO、zh-Hant）需要在 ^zh 之前匹配
+var maintenanceLanguages = []struct {
+	Lang    string
+	Pattern string
+}{
+	{"zh-cht", "^zh-(tw|hk|mo|hant)"},
+	{"zh", "^zh"},
+	{"ja", "^ja"},
+	{"ko", "^ko"},
+	{"de", "^de"},
+	{"fr", "^fr"},
+	{"id", "^id"},
+	{"ru", "^ru"},
+}
+
+// startMaintenance 将应用的nginx配置替换为维护页面并重新加载nginx，返回是否已启用
+// - 原配置暂存为 *.maintenance，由 stopMaintenance 恢复
+// - 没有nginx配置（例如首次安装）或测试配置失败时不启用
+func startMaintenance(appId string) bool {
+	nginxMutex.Lock()
+	defer nginxMutex.Unlock()
+
+	if !HasNginxConfig(appId) || inMaintenance(appId) {
+		return false
+	}
+
+	// 生成维护页面配置
+	files := map[string]string{}
+	for _, name := range nginxConfigFiles {
+		data, err := os.ReadFile(nginxConfigPath(appId, name))
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		if name == "server.conf" {
+			host, err := AppHost(appId)
+			if err != nil {
+				continue
+			}
+			files[name] = renderNginxServer(host, renderMaintenanceLocations(appId, []nginxLocate{{Path: "/"}}))
+		} else if locations := parseNginxLocations(string(data)); len(locations) > 0 {
+			files[name] = renderMaintenanceLocations(appId, locations)
+		}
+	}
+	if len(files) == 0 {
+		return false
+	}
+
+	// 暂存原配置，写入维护页面配置
+	for name, content := range files {
+		configPath := nginxConfigPath(appId, name)
+		if err := os.Rename(configPath, configPath+maintenanceSuffix); err != nil {
+			AppLogWarn(appId, "maintenance page skipped: "+err.Error())
+			stopMaintenance(appId)
+			return false
+		}
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			AppLogWarn(appId, "maintenance page skipped: "+err.Error())
+			stopMaintenance(appId)
+			return false
+		}
+	}
+
+	// 重新加载nginx，失败时恢复原配置
+	if out, err := reloadNginx(appId, 1); err != nil {
+		AppLogWarn(appId, "maintenance page skipped: "+err.Error()+" "+out)
+		stopMaintenance(appId)
+		return false
+	}
+	AppLogInfo(appId, "maintenance page enabled")
+	return true
+}
+
+// stopMaintenance 恢复维护前的nginx配置（不重新加载nginx），返回是否处于维护中
+func stopMaintenance(appId string) bool {
+	restored := false
+	for _, name := range nginxConfigFiles {
+		configPath := nginxConfigPath(appId, name)
+		if !utils.IsFileExists(configPath + maintenanceSuffix) {
+			continue
+		}
+		if err := os.Rename(configPath+maintenanceSuffix, configPath); err != nil {
+			AppLogError(appId, "Failed to restore nginx config: "+err.Error())
+			continue
+		}
+		restored = true
+	}
+	if restored {
+		AppLogInfo(appId, "maintenance page disabled")
+	}
+	return restored
+}
+
+// inMaintenance 应用是否处于维护中
+func inMaintenance(appId string) bool {
+	for _, name := range nginxConfigFiles {
+		if utils.IsFileExists(nginxConfigPath(appId, name) + maintenanceSuffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderMaintenanceLocations 生成返回维护页面（503）的 location 配置，按 Accept-Language 选择语言
+func renderMaintenanceLocations(appId string, locations []nginxLocate) string {
+	var rawName interface{}
+	if data, err := os.ReadFile(filepath.Join(global.WorkDir, "apps", appId, "config.yml")); err == nil {
+		if manifest, _, err := decodeManifestLenient(data); err == nil {
+			rawName = manifest.Name
+		}
+	}
+	page := func(lang string) string {
+		name := getLocalizedValue(rawName, lang)
+		if name == "" {
+			name = appId
+		}
+		return maintenancePage(lang, name)
+	}
+
+	var builder strings.Builder
+	for i, location := range locations {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		path := location.Path
+		if strings.ContainsAny(path, " {};") {
+			path = `"` + strings.ReplaceAll(path, `"`, `\"`) + `"`
+		}
+		if location.Modifier != "" {
+			path = location.Modifier + " " + path
+		}
+		fmt.Fprintf(&builder, "location %s {\n", path)
+		builder.WriteString("    default_type text/html;\n")
+		fmt.Fprintf(&builder, "    add_header Retry-After %d always;\n", maintenanceRetryAfter)
+		builder.WriteString("    add_header Cache-Control \"no-store\" always;\n")
+		for _, item := range maintenanceLanguages {
+			fmt.Fprintf(&builder, "    if ($http_accept_language ~* \"%s\") {\n", item.Pattern)
+			fmt.Fprintf(&builder, "        return 503 '%s';\n", page(item.Lang))
+			builder.WriteString("    }\n")
+		}
+		fmt.Fprintf(&builder, "    return 503 '%s';\n", page("en"))
+		builder.WriteString("}\n")
+	}
+	return builder.String()
+}
+
+// maintenancePage 维护页面内容（转义为nginx单引号字符串）
+func maintenancePage(lang, appName string) string {
+	title := html.EscapeString(i18n.TL(lang, "MaintenanceTitle"))
+	message := html.EscapeString(i18n.TL(lang, "MaintenanceMessage", map[string]interface{}{"app": appName}))
+	page := fmt.Sprintf(`<!DOCTYPE html><html lang="%s"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width,initial-scale=1"><meta http-equiv="refresh" content="%d"><title>%s</title><style>body{margin:0;height:100vh;display:flex;align-items:center;justify-content:center;font-family:sans-serif;color:#555;background:#f7f8fa}div{text-align:center;padding:24px}h1{font-size:20px;color:#333}</style></head><body><div><h1>%s</h1><p>%s</p></div></body></html>`,
+		lang, maintenanceRetryAfter, title, title, message)
+	// nginx 字符串中 $ 会被解析为变量
+	page = strings.ReplaceAll(page, "$", "&#36;")
+	page = strings.ReplaceAll(page, `\`, `\\`)
+	return strings.ReplaceAll(page, "'", `\'`)
+}
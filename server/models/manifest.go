@@ -0,0 +1,120 @@
+package models
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"appstore/server/global"
+	"appstore/server/i18n"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestSchema 应用配置文件（config.yml）的 JSON Schema
+//
+//go:embed manifest.schema.json
+var ManifestSchema []byte
+
+// FieldTypes 支持的字段类型
+var FieldTypes = []string{"text", "number", "select"}
+
+// MenuLocations 支持的菜单位置
+var MenuLocations = []string{"application", "application/admin", "main/menu"}
+
+// AppManifest 应用配置文件（config.yml）结构，用于严格校验，不允许未知的配置项
+type AppManifest struct {
+	Name              interface{}                     `yaml:"name"`
+	Description       interface{}                     `yaml:"description"`
+	Tags              []string                        `yaml:"tags"`
+	Author            string                          `yaml:"author"`
+	Website           string                          `yaml:"website"`
+	Github            string                          `yaml:"github"`
+	Document          string                          `yaml:"document"`
+	DooTaskVersion    string                          `yaml:"dootask_version"`
+	Platforms         []string                        `yaml:"platforms"`
+	Compatibility     map[string]VersionCompatibility `yaml:"compatibility"`
+	Fields            []FieldConfig                   `yaml:"fields"`
+	RequireUninstalls []RequireUninstall              `yaml:"require_uninstalls"`
+	Requires          []AppRequire                    `yaml:"requires"`
+	Conflicts         []AppConflict                   `yaml:"conflicts"`
+	Changelog         map[string]interface{}          `yaml:"changelog"`
+	MenuItems         []MenuItem                      `yaml:"menu_items"`
+	Routing           string                          `yaml:"routing"`
+	BlueGreen         string                          `yaml:"blue_green"`
+	HealthChecks      []HealthCheck                   `yaml:"health_checks"`
+}
+
+// DecodeManifest 严格解析应用配置文件，存在未知的配置项或类型错误时返回错误（lint、pack 使用）
+func DecodeManifest(data []byte) (*AppManifest, error) {
+	manifest := &AppManifest{}
+	if err := decodeStrict(data, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// decodeManifestLenient 解析应用配置文件，忽略未知的配置项并返回其说明，类型错误时返回错误
+func decodeManifestLenient(data []byte) (*AppManifest, []string, error) {
+	manifest := &AppManifest{}
+	err := decodeStrict(data, manifest)
+	if err == nil {
+		return manifest, nil, nil
+	}
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return nil, nil, err
+	}
+	unknown := []string{}
+	for _, message := range yamlErrorMessages(err) {
+		if !strings.Contains(message, "unknown key") {
+			return nil, nil, err
+		}
+		unknown = append(unknown, message)
+	}
+	return manifest, unknown, nil
+}
+
+// ValidateManifest 检查应用配置文件，返回失败原因（为空表示通过）
+// - 未知的配置项（例如更新版本的应用商店新增的配置项）只记录警告日志，严格检查由 lint、pack 执行
+func ValidateManifest(appId string, data []byte) string {
+	manifest, unknown, err := decodeManifestLenient(data)
+	if err != nil {
+		return i18n.T("InvalidManifest", strings.Join(yamlErrorMessages(err), "; "))
+	}
+	for _, message := range unknown {
+		AppLogWarn(appId, "config.yml: "+message)
+	}
+	if getLocalizedValue(manifest.Name, global.Language) == "" {
+		return i18n.T("InvalidConfig")
+	}
+	return ""
+}
+
+var yamlUnknownFieldRegex = regexp.MustCompile(`field (\S+) not found in type \S+`)
+
+// yamlErrorMessages 将YAML解析错误拆分为逐条说明，例如 line 3: unknown key "foo"
+func yamlErrorMessages(err error) []string {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return []string{err.Error()}
+	}
+	messages := []string{}
+	for _, message := range typeErr.Errors {
+		messages = append(messages, yamlUnknownFieldRegex.ReplaceAllString(message, `unknown key "$1"`))
+	}
+	return messages
+}
+
+// decodeStrict 严格解析YAML，不允许未知的字段，空内容不报错
+func decodeStrict(data []byte, out interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"appstore/server/global"
@@ -12,49 +16,237 @@ import (
 	"appstore/server/utils"
 )
 
-// GenerateNginxConfig 生成nginx配置文件
-func GenerateNginxConfig(appId string, version string, config *AppConfig) error {
-	// 读取应用的nginx配置模板
+// nginxDefaultParamRegex 带默认值的占位符，例如 ${PORT:-8080}、${PORT-8080}
+var nginxDefaultParamRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*):?-([^}]*)\}`)
+
+// nginxUpperParamRegex 大写的占位符（nginx变量为小写，例如 ${host}），替换后仍存在表示缺少参数
+var nginxUpperParamRegex = regexp.MustCompile(`\$\{([A-Z][A-Z0-9_]*)\}`)
+
+// renderNginxTemplate 读取应用版本的nginx配置模板并替换参数，版本没有nginx配置时返回空
+// - ${KEY:-默认值} 没有对应参数时使用默认值
+// - 替换后仍有 ${UPPER_CASE} 占位符时返回错误（避免容器替换后才被 nginx -t 发现）
+func renderNginxTemplate(appId string, version string, params map[string]interface{}) (string, error) {
 	templatePath := filepath.Join(global.WorkDir, "apps", appId, version, "nginx.conf")
 	templateData, err := os.ReadFile(templatePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil
+			return "", nil
+		}
+		return "", errors.New(i18n.T("ReadNginxTemplateFailed", err))
+	}
+	content := renderTemplateParams(string(templateData), params)
+	content = nginxDefaultParamRegex.ReplaceAllStringFunc(content, func(match string) string {
+		matches := nginxDefaultParamRegex.FindStringSubmatch(match)
+		if value, ok := params[matches[1]]; ok && fmt.Sprintf("%v", value) != "" {
+			return fmt.Sprintf("%v", value)
+		}
+		return matches[2]
+	})
+	unresolved := []string{}
+	for _, matches := range nginxUpperParamRegex.FindAllStringSubmatch(content, -1) {
+		if !slices.Contains(unresolved, matches[0]) {
+			unresolved = append(unresolved, matches[0])
+		}
+	}
+	if len(unresolved) > 0 {
+		return "", errors.New(i18n.T("NginxPlaceholderUnresolved", strings.Join(unresolved, ", ")))
+	}
+	return content, nil
+}
+
+// nginxMutex 串行执行nginx配置的替换、测试和重新加载，避免测试或加载到其他应用未经测试的配置
+var nginxMutex sync.Mutex
+
+// nginxConfigFiles 应用的nginx配置文件
+// - nginx.conf：路径前缀路由的 location，包含在 DooTask 的 server 块中
+// - server.conf：子域名路由的 server 块，包含在 nginx 的 http 块中
+var nginxConfigFiles = []string{"nginx.conf", "server.conf"}
+
+// GenerateNginxConfig 生成待生效的nginx配置文件（nginx.conf.new、server.conf.new）
+// - 模板中的 ${KEY} 替换为应用参数的值（与 docker-compose.yml 相同）
+// - 子域名路由时将模板包装为 <应用ID>.<基础域名> 的 server 块，写入 server.conf
+// - nginx 只加载 nginx.conf 和 server.conf，容器启动后由 ApplyNginxConfig 测试通过再替换
+// - 不需要的配置文件生成空的待生效文件，启用时删除
+func GenerateNginxConfig(appId string, version string, config *AppConfig) error {
+	app, err := NewApp(appId)
+	if err != nil {
+		return err
+	}
+
+	// 读取应用的nginx配置模板并替换参数
+	content, err := renderNginxTemplate(appId, version, config.Params)
+	if err != nil {
+		return err
+	}
+	files := map[string]string{}
+	if content != "" {
+		// 蓝绿升级的版本代理到当前项目的服务别名
+		if app.IsBlueGreen(version) {
+			composeMap, err := renderDockerComposeTemplate(appId, version, config.Params)
+			if err != nil {
+				return err
+			}
+			services := []string{}
+			for service := range composeMap["services"].(map[string]interface{}) {
+				services = append(services, service)
+			}
+			content = rewriteNginxUpstreams(content, composeProject(appId, config), services)
+		}
+		if app.IsSubdomainRouting() {
+			host, err := AppHost(appId)
+			if err != nil {
+				return err
+			}
+			files["server.conf"] = renderNginxServer(host, content)
 		} else {
-			return errors.New(i18n.T("ReadNginxTemplateFailed", err))
+			files["nginx.conf"] = content
 		}
 	}
 
-	// 生成新的nginx配置文件
-	outputPath := filepath.Join(global.WorkDir, "config", appId, "nginx.conf")
-	if err := os.WriteFile(outputPath, templateData, 0644); err != nil {
-		return errors.New(i18n.T("SaveNginxConfigFailed", err))
+	// 生成待生效的nginx配置文件
+	for _, name := range nginxConfigFiles {
+		if err := os.WriteFile(nginxStagedPath(appId, name), []byte(files[name]), 0644); err != nil {
+			return errors.New(i18n.T("SaveNginxConfigFailed", err))
+		}
 	}
 
 	return nil
 }
 
-// DeleteNginxConfig 删除nginx配置
+// ApplyNginxConfig 启用待生效的nginx配置并重新加载nginx
+// - 先备份当前配置，替换后执行 nginx -t
+// - 测试失败时删除新配置、恢复原配置，不重新加载nginx，返回nginx的错误信息
+// - 没有待生效的配置时只重新加载当前配置
+func ApplyNginxConfig(appId string, retry int) (string, error) {
+	nginxMutex.Lock()
+	defer nginxMutex.Unlock()
+
+	if !hasStagedNginxConfig(appId) {
+		return reloadNginx(appId, retry)
+	}
+
+	// 恢复原配置
+	restore := func() {
+		for _, name := range nginxConfigFiles {
+			configPath := nginxConfigPath(appId, name)
+			os.Remove(configPath)
+			if utils.IsFileExists(configPath + ".prev") {
+				_ = os.Rename(configPath+".prev", configPath)
+			}
+		}
+	}
+
+	// 备份当前配置，替换为新配置（待生效的配置为空时删除）
+	for _, name := range nginxConfigFiles {
+		configPath := nginxConfigPath(appId, name)
+		stagedPath := nginxStagedPath(appId, name)
+		os.Remove(configPath + ".prev")
+		if !utils.IsFileExists(stagedPath) {
+			continue
+		}
+		if utils.IsFileExists(configPath) {
+			if err := os.Rename(configPath, configPath+".prev"); err != nil {
+				restore()
+				return "", errors.New(i18n.T("SaveNginxConfigFailed", err))
+			}
+		}
+		if info, err := os.Stat(stagedPath); err == nil && info.Size() == 0 {
+			os.Remove(stagedPath)
+			continue
+		}
+		if err := os.Rename(stagedPath, configPath); err != nil {
+			restore()
+			return "", errors.New(i18n.T("SaveNginxConfigFailed", err))
+		}
+	}
+
+	// 测试新配置，失败时恢复原配置
+	if out, err := TestNginxConfig(retry); err != nil {
+		restore()
+		return out, err
+	}
+	for _, name := range nginxConfigFiles {
+		os.Remove(nginxConfigPath(appId, name) + ".prev")
+	}
+
+	return execNginx("nginx -s reload", retry)
+}
+
+// DeleteNginxConfig 删除nginx配置（包括待生效和维护期间暂存的配置）
 func DeleteNginxConfig(appId string) {
-	if hasNginxConfig, nginxConfigPath := HasNginxConfig(appId); hasNginxConfig {
-		os.Remove(nginxConfigPath)
+	for _, name := range nginxConfigFiles {
+		os.Remove(nginxConfigPath(appId, name))
+		os.Remove(nginxConfigPath(appId, name) + maintenanceSuffix)
+	}
+	removeStagedNginxConfig(appId)
+}
+
+// removeStagedNginxConfig 删除待生效的nginx配置
+func removeStagedNginxConfig(appId string) {
+	for _, name := range nginxConfigFiles {
+		os.Remove(nginxStagedPath(appId, name))
+	}
+}
+
+// hasStagedNginxConfig 是否有待生效的nginx配置
+func hasStagedNginxConfig(appId string) bool {
+	for _, name := range nginxConfigFiles {
+		if utils.IsFileExists(nginxStagedPath(appId, name)) {
+			return true
+		}
 	}
+	return false
+}
+
+// nginxConfigPath nginx配置文件路径
+func nginxConfigPath(appId, name string) string {
+	return filepath.Join(global.WorkDir, "config", appId, name)
 }
 
-// ReloadNginx 重启nginx
+// nginxStagedPath 待生效的nginx配置文件路径
+func nginxStagedPath(appId, name string) string {
+	return nginxConfigPath(appId, name) + ".new"
+}
+
+// TestNginxConfig 在nginx容器中执行 nginx -t 测试配置，失败时返回nginx的错误信息
+func TestNginxConfig(retry int) (string, error) {
+	out, err := execNginx("nginx -t", retry)
+	if err != nil {
+		return out, errors.New(i18n.T("NginxConfigTestFailed", nginxErrorText(out, err)))
+	}
+	return out, nil
+}
+
+// ReloadNginx 重启nginx（先测试配置，测试失败时不重启）
 func ReloadNginx(appId string, retry int) (string, error) {
-	if hasNginxConfig, _ := HasNginxConfig(appId); !hasNginxConfig {
+	nginxMutex.Lock()
+	defer nginxMutex.Unlock()
+
+	return reloadNginx(appId, retry)
+}
+
+// reloadNginx 重启nginx（需持有 nginxMutex）
+func reloadNginx(appId string, retry int) (string, error) {
+	if !HasNginxConfig(appId) {
 		return "", nil
 	}
 
+	if out, err := TestNginxConfig(retry); err != nil {
+		return out, err
+	}
+	return execNginx("nginx -s reload", retry)
+}
+
+// execNginx 在nginx容器中执行命令，失败时重试
+func execNginx(command string, retry int) (string, error) {
 	// 容器名称
 	nginxContainerName := "dootask-nginx-" + os.Getenv("APP_ID")
 	if utils.CheckIllegal(nginxContainerName) {
 		return "Invalid parameter", errors.New("nginx name contains illegal characters")
 	}
 
-	// 执行重启
-	nginxCmd := fmt.Sprintf("docker exec -i %s %s", nginxContainerName, "nginx -s reload")
+	// 执行命令
+	nginxCmd := fmt.Sprintf("docker exec -i %s %s", nginxContainerName, command)
 
 	var out string
 	var err error
@@ -80,8 +272,33 @@ func ReloadNginx(appId string, retry int) (string, error) {
 	return out, nil
 }
 
+// nginxErrorText 提取nginx输出中的错误信息（[emerg] 等），没有时返回原始输出
+func nginxErrorText(out string, err error) string {
+	text := err.Error()
+	if out != "" && !strings.HasPrefix(out, "Command execution failed") {
+		text = out
+	}
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.Contains(line, "[emerg]") || strings.Contains(line, "[alert]") || strings.Contains(line, "[crit]") {
+			if index := strings.Index(line, "["); index >= 0 {
+				line = line[index:]
+			}
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	if len(lines) == 0 {
+		return strings.TrimSpace(text)
+	}
+	return strings.Join(lines, "; ")
+}
+
 // HasNginxConfig 是否有nginx配置
-func HasNginxConfig(appId string) (bool, string) {
-	nginxConfigPath := filepath.Join(global.WorkDir, "config", appId, "nginx.conf")
-	return utils.IsFileExists(nginxConfigPath), nginxConfigPath
+func HasNginxConfig(appId string) bool {
+	for _, name := range nginxConfigFiles {
+		if utils.IsFileExists(nginxConfigPath(appId, name)) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,327 @@
+package models
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"appstore/server/utils"
+)
+
+// scaffoldFS 新建应用的骨架模板（version 目录会替换为实际版本号）
+//
+//go:embed scaffold
+var scaffoldFS embed.FS
+
+// appIdRegex 应用ID格式
+var appIdRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// packageModTime 应用包内文件的修改时间（固定值，保证相同内容生成相同的包）
+var packageModTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// packageIgnoreNames 打包时忽略的文件和目录
+var packageIgnoreNames = []string{".git", ".svn", ".idea", ".vscode", ".DS_Store", "Thumbs.db"}
+
+// ScaffoldOptions 新建应用骨架的参数
+type ScaffoldOptions struct {
+	AppID   string // 应用ID（默认为目录名）
+	Name    string // 应用名称
+	Author  string // 作者
+	Version string // 初始版本
+	Image   string // 容器镜像
+	Port    int    // 容器内服务端口
+}
+
+// PackageResult 应用打包结果
+type PackageResult struct {
+	AppID        string `json:"id"`
+	Version      string `json:"version,omitempty"` // 只打包指定版本时的版本号
+	File         string `json:"file"`              // 应用包文件路径
+	ChecksumFile string `json:"checksum_file"`     // 摘要文件路径（sha256sum 格式）
+	Size         int64  `json:"size"`
+	SHA256       string `json:"sha256"` // 应用包文件摘要（sha256:xxx），上传时可用于校验
+	Digest       string `json:"digest"` // 应用内容摘要（与仓库索引中的摘要一致）
+}
+
+// CreateAppScaffold 在指定目录生成应用骨架，返回生成的文件（相对路径）
+// - 目录必须不存在或为空
+// - 生成 config.yml、多语言 README、logo.svg 以及初始版本的 docker-compose.yml 和 nginx.conf
+func CreateAppScaffold(dir string, opts ScaffoldOptions) ([]string, error) {
+	if opts.AppID == "" {
+		opts.AppID = filepath.Base(filepath.Clean(dir))
+	}
+	if !appIdRegex.MatchString(opts.AppID) || slices.Contains(ProtectedNames, opts.AppID) {
+		return nil, fmt.Errorf("invalid app id %q: use lowercase letters, digits, - and _", opts.AppID)
+	}
+	if opts.Name == "" {
+		opts.Name = opts.AppID
+	}
+	if opts.Version == "" {
+		opts.Version = "1.0.0"
+	}
+	if !utils.IsValidVersion(opts.Version) {
+		return nil, fmt.Errorf("invalid version %q", opts.Version)
+	}
+	if opts.Image == "" {
+		opts.Image = "nginx:alpine"
+	}
+	if opts.Port <= 0 {
+		opts.Port = 80
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("directory %s is not empty", dir)
+	}
+
+	data := map[string]interface{}{
+		"AppID":   opts.AppID,
+		"Name":    opts.Name,
+		"Author":  opts.Author,
+		"Version": opts.Version,
+		"Image":   opts.Image,
+		"Port":    opts.Port,
+		"Initial": strings.ToUpper(string([]rune(opts.Name)[:1])),
+	}
+
+	files := []string{}
+	err := fs.WalkDir(scaffoldFS, "scaffold", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relPath := strings.TrimSuffix(strings.TrimPrefix(name, "scaffold/"), ".tmpl")
+		if dir, file := path.Split(relPath); dir == "version/" {
+			relPath = opts.Version + "/" + file
+		}
+
+		content, err := scaffoldFS.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(relPath).Parse(string(content))
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		files = append(files, relPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// PackApp 检查应用目录并生成可复现的应用包（可通过上传接口安装）
+// - 检查不通过时返回检查结果，不生成应用包
+// - version 不为空时只打包该版本目录
+// - 应用包写入 outputDir，文件名为 <应用ID>.tar.gz 或 <应用ID>-<版本>.tar.gz，同时写入 .sha256 摘要文件（不生成签名）
+// - 跳过应用目录下之前生成的应用包、摘要文件和临时文件（例如 pack .），outputDir 在应用目录内时跳过输出目录
+func PackApp(appDir, version, outputDir string) (*PackageResult, *LintResult, error) {
+	lint := LintApp(appDir)
+	if !lint.Valid {
+		return nil, lint, fmt.Errorf("%s has %d lint error(s)", lint.AppID, lint.Errors)
+	}
+	if version != "" && !slices.Contains(lint.Versions, version) {
+		return nil, lint, fmt.Errorf("version %s not found in %s", version, lint.AppID)
+	}
+
+	// 输出目录在应用目录内时相对于应用目录的路径，否则为空
+	outputRel := ""
+	if absApp, err := filepath.Abs(lint.Dir); err == nil {
+		if absOutput, err := filepath.Abs(outputDir); err == nil {
+			if rel, err := filepath.Rel(absApp, absOutput); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				outputRel = rel
+			}
+		}
+	}
+
+	// 跳过忽略的文件、之前生成的应用包和输出目录，只打包指定版本时跳过其他版本目录
+	skip := func(relPath string, info os.FileInfo) bool {
+		if slices.Contains(packageIgnoreNames, info.Name()) {
+			return true
+		}
+		if outputRel != "" && outputRel != "." && relPath == outputRel {
+			return true
+		}
+		if !info.IsDir() && !strings.Contains(relPath, string(filepath.Separator)) && isPackageOutput(info.Name()) {
+			return true
+		}
+		if version == "" || !info.IsDir() {
+			return false
+		}
+		parts := strings.Split(relPath, string(filepath.Separator))
+		return len(parts) == 1 && utils.IsValidVersion(parts[0]) && parts[0] != version
+	}
+
+	digest, err := utils.DirDigest(lint.Dir, skip)
+	if err != nil {
+		return nil, lint, err
+	}
+
+	filename := lint.AppID + ".tar.gz"
+	if version != "" {
+		filename = fmt.Sprintf("%s-%s.tar.gz", lint.AppID, version)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, lint, err
+	}
+	target := filepath.Join(outputDir, filename)
+
+	// 先写入临时文件，同时计算应用包摘要
+	tempFile, err := os.CreateTemp(outputDir, "."+filename+".*")
+	if err != nil {
+		return nil, lint, err
+	}
+	defer os.Remove(tempFile.Name())
+	hash := sha256.New()
+	counter := &countingWriter{}
+	if err := WriteAppPackage(io.MultiWriter(tempFile, hash, counter), lint.Dir, skip); err != nil {
+		tempFile.Close()
+		return nil, lint, err
+	}
+	if err := tempFile.Close(); err != nil {
+		return nil, lint, err
+	}
+	if err := os.Rename(tempFile.Name(), target); err != nil {
+		return nil, lint, err
+	}
+
+	// 写入摘要文件
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if err := os.WriteFile(target+".sha256", []byte(fmt.Sprintf("%s  %s\n", sum, filename)), 0644); err != nil {
+		return nil, lint, err
+	}
+
+	return &PackageResult{
+		AppID:        lint.AppID,
+		Version:      version,
+		File:         target,
+		ChecksumFile: target + ".sha256",
+		Size:         counter.n,
+		SHA256:       utils.DigestPrefix + sum,
+		Digest:       digest.Digest,
+	}, lint, nil
+}
+
+// isPackageOutput 是否为 pack 生成的文件（应用包、摘要文件、写入中的临时文件）
+func isPackageOutput(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tar.gz.sha256") || (strings.HasPrefix(name, ".") && strings.Contains(name, ".tar.gz."))
+}
+
+// WriteAppPackage 将应用目录写入tar.gz（路径相对于应用目录）
+// - 按路径排序，统一修改时间、属主和权限，相同内容生成相同的应用包
+// - skip 返回 true 时跳过该路径（目录则跳过整个目录），可为 nil
+func WriteAppPackage(w io.Writer, appDir string, skip func(relPath string, info os.FileInfo) bool) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	err := filepath.Walk(appDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(appDir, filePath)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		if skip != nil && skip(relPath, info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// 创建tar文件头
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(filePath); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		header.ModTime = packageModTime
+		header.AccessTime = time.Time{}
+		header.ChangeTime = time.Time{}
+		header.Uid, header.Gid = 0, 0
+		header.Uname, header.Gname = "", ""
+		header.Mode = 0644
+		if info.IsDir() || info.Mode()&0111 != 0 {
+			header.Mode = 0755
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// MatchFileDigest 校验文件的sha256摘要（支持 sha256:xxx 或 xxx 格式），返回实际摘要和是否一致
+func MatchFileDigest(filePath, expected string) (string, bool) {
+	actual, err := utils.FileDigest(filePath)
+	if err != nil {
+		return "", false
+	}
+	return utils.DigestPrefix + actual, strings.EqualFold(strings.TrimPrefix(expected, utils.DigestPrefix), actual)
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"appstore/server/global"
+	"appstore/server/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 应用包来源类型
+const (
+	AppSourceRepo   = "repo"   // 应用商店仓库（更新应用列表）
+	AppSourceURL    = "url"    // 通过URL下载
+	AppSourceGit    = "git"    // 通过Git克隆
+	AppSourceUpload = "upload" // 本地上传
+)
+
+// AppSource 应用包来源
+type AppSource struct {
+	Type         string `yaml:"type" json:"type"`                                       // repo, url, git, upload
+	URL          string `yaml:"url,omitempty" json:"url,omitempty"`                     // 仓库地址或下载地址
+	Filename     string `yaml:"filename,omitempty" json:"filename,omitempty"`           // 上传的文件名
+	Digest       string `yaml:"digest" json:"digest"`                                   // 写入apps目录时的内容摘要（不含已安装版本的运行时数据）
+	RemoteDigest string `yaml:"remote_digest,omitempty" json:"remote_digest,omitempty"` // 同步时仓库索引中的摘要
+	UpdatedAt    string `yaml:"updated_at" json:"updated_at"`
+	Modified     bool   `yaml:"-" json:"modified"` // 当前内容与记录的摘要不一致（本地被修改）
+}
+
+// appSourceFile 应用包来源文件路径
+func appSourceFile(appId string) string {
+	return filepath.Join(global.WorkDir, "config", appId, "source.yml")
+}
+
+// GetAppSource 获取应用包来源，没有记录时返回nil
+func GetAppSource(appId string) *AppSource {
+	data, err := os.ReadFile(appSourceFile(appId))
+	if err != nil {
+		return nil
+	}
+	source := &AppSource{}
+	if err := yaml.Unmarshal(data, source); err != nil || source.Type == "" {
+		return nil
+	}
+	return source
+}
+
+// SaveAppSource 记录应用包来源，摘要为当前apps目录内容
+func SaveAppSource(appId string, source *AppSource) error {
+	digest, err := appSourceDigest(appId)
+	if err != nil {
+		return err
+	}
+	source.Digest = digest.Digest
+	source.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+
+	if err := os.MkdirAll(filepath.Dir(appSourceFile(appId)), 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(source)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(appSourceFile(appId), data, 0644)
+}
+
+// checkAppSourceModified 检查应用内容是否在记录来源后被修改
+func checkAppSourceModified(appId string, source *AppSource) bool {
+	if source == nil {
+		return false
+	}
+	digest, err := appSourceDigest(appId)
+	if err != nil {
+		return false
+	}
+	return digest.Digest != source.Digest
+}
+
+// appSourceDigest 计算用于跟踪本地修改的应用内容摘要，跳过已安装版本挂载到容器中的路径（容器运行时写入的数据）
+func appSourceDigest(appId string) (*utils.DirDigestResult, error) {
+	return utils.DirDigest(filepath.Join(global.WorkDir, "apps", appId), appRuntimeSkip(appId, ""))
+}
+
+// appRuntimeSkip 返回跳过已安装版本运行时路径的 DirDigest 过滤函数，base 为摘要目录相对于应用目录的路径（应用目录为空）
+func appRuntimeSkip(appId, base string) func(relPath string, info os.FileInfo) bool {
+	runtimePaths := appRuntimePaths(appId)
+	return func(relPath string, info os.FileInfo) bool {
+		relPath = filepath.ToSlash(filepath.Join(base, relPath))
+		for _, path := range runtimePaths {
+			if relPath == path || strings.HasPrefix(relPath, path+"/") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// appRuntimePaths 已安装版本挂载到容器中的路径（相对于应用目录，例如 1.0.0/data），从生成的docker-compose.yml中读取
+func appRuntimePaths(appId string) []string {
+	version := appInstalledVersion(appId)
+	if version == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(global.WorkDir, "config", appId, "docker-compose.yml"))
+	if err != nil {
+		return nil
+	}
+	composeMap := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &composeMap); err != nil {
+		return nil
+	}
+
+	versionPwd := filepath.Join(global.HostWorkDir, "apps", appId, version)
+	paths := []string{}
+	services, _ := composeMap["services"].(map[string]interface{})
+	for _, service := range services {
+		serviceMap, _ := service.(map[string]interface{})
+		volumes, _ := serviceMap["volumes"].([]interface{})
+		for _, volume := range volumes {
+			source := ""
+			if volumeMap, ok := volume.(map[string]interface{}); ok {
+				source, _ = volumeMap["source"].(string)
+			} else if volumeStr, ok := volume.(string); ok {
+				source, _, _ = strings.Cut(volumeStr, ":")
+			}
+			rel, err := filepath.Rel(versionPwd, source)
+			if source == "" || err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			paths = append(paths, filepath.ToSlash(filepath.Join(version, rel)))
+		}
+	}
+	return paths
+}
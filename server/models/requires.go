@@ -0,0 +1,274 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"appstore/server/global"
+	"appstore/server/i18n"
+	"appstore/server/utils"
+)
+
+// AppRequire 定义依赖的其他应用结构
+type AppRequire struct {
+	App      string `yaml:"app" json:"app"`           // 依赖的应用ID
+	Version  string `yaml:"version" json:"version"`   // 允许的版本范围，例如 ^1.0，为空表示任意版本
+	Optional bool   `yaml:"optional" json:"optional"` // 可选依赖：未安装时不影响安装，已安装时需满足版本范围
+}
+
+// requireInstallStep 依赖安装步骤
+type requireInstallStep struct {
+	AppID   string
+	Version string
+	Params  map[string]interface{}
+}
+
+// resolveRequires 检查应用依赖，返回需要先安装的依赖（按安装顺序，依赖的依赖在前）
+// - 已安装的依赖（包括可选依赖）需满足版本范围
+// - install 为 false 时，缺少必需依赖返回错误；为 true 时，缺少的必需依赖加入安装步骤
+func resolveRequires(appId string, install bool) ([]requireInstallStep, string, error) {
+	steps := []requireInstallStep{}
+	stderr, err := collectRequires(appId, install, []string{appId}, &steps)
+	return steps, stderr, err
+}
+
+// collectRequires 递归收集应用需要先安装的依赖，chain 为当前依赖链（用于检查循环依赖）
+func collectRequires(appId string, install bool, chain []string, steps *[]requireInstallStep) (string, error) {
+	app, err := NewApp(appId)
+	if err != nil {
+		if len(chain) > 1 {
+			return i18n.T("RequireAppNotFound", appId), err
+		}
+		return i18n.T("GetAppDetailFailed"), err
+	}
+
+	missing := []string{}
+	for _, require := range app.Requires {
+		if require.Version != "" {
+			if err := utils.ValidateVersionConstraint(require.Version); err != nil {
+				return i18n.T("InvalidVersionRange", require.Version), err
+			}
+		}
+		if slices.Contains(chain, require.App) {
+			path := strings.Join(append(slices.Clone(chain), require.App), " -> ")
+			return i18n.T("RequireCycle", path), errors.New(path)
+		}
+
+		// 已安装的依赖需满足版本范围
+		requireConfig := GetAppConfig(require.App)
+		if requireConfig.Status == "installed" && requireConfig.InstallVersion != "" {
+			if !utils.CheckVersionConstraint(requireConfig.InstallVersion, require.Version) {
+				message := i18n.T("RequireVersionMismatch", map[string]interface{}{
+					"appId":     appId,
+					"require":   require.App,
+					"version":   require.Version,
+					"installed": requireConfig.InstallVersion,
+				})
+				return message, errors.New(message)
+			}
+			continue
+		}
+		if require.Optional || slices.ContainsFunc(*steps, func(step requireInstallStep) bool {
+			return step.AppID == require.App
+		}) {
+			continue
+		}
+		if !install {
+			missing = append(missing, requireLabel(require))
+			continue
+		}
+
+		// 缺少的依赖：先安装依赖的依赖
+		step, stderr, err := newRequireInstallStep(require)
+		if err != nil {
+			return stderr, err
+		}
+		if stderr, err := collectRequires(require.App, install, append(slices.Clone(chain), require.App), steps); err != nil {
+			return stderr, err
+		}
+		*steps = append(*steps, *step)
+	}
+
+	if len(missing) > 0 {
+		message := i18n.T("MissingRequires", strings.Join(missing, ", "))
+		return message, errors.New(message)
+	}
+	return "", nil
+}
+
+// newRequireInstallStep 生成依赖的安装步骤：选择版本范围内的最新版本，参数使用字段默认值
+func newRequireInstallStep(require AppRequire) (*requireInstallStep, string, error) {
+	app, err := NewApp(require.App)
+	if err != nil {
+		return nil, i18n.T("RequireAppNotFound", require.App), err
+	}
+
+	var version string
+	for _, v := range app.Versions {
+		if utils.CheckVersionConstraint(v, require.Version) {
+			version = v
+			break
+		}
+	}
+	if version == "" {
+		message := i18n.T("RequireVersionNotFound", map[string]interface{}{
+			"require": require.App,
+			"version": require.Version,
+		})
+		return nil, message, errors.New(message)
+	}
+
+	params := map[string]interface{}{}
+	for _, field := range app.Fields {
+		if field.Default != nil {
+			params[field.Name] = field.Default
+		} else if field.Required {
+			message := i18n.T("RequireNeedsConfig", require.App)
+			return nil, message, errors.New(message)
+		}
+	}
+
+	return &requireInstallStep{AppID: require.App, Version: version, Params: params}, "", nil
+}
+
+// requireLabel 依赖的显示名称，例如 search (^1.0)
+func requireLabel(require AppRequire) string {
+	if require.Version == "" {
+		return require.App
+	}
+	return fmt.Sprintf("%s (%s)", require.App, require.Version)
+}
+
+// FindDependents 查找依赖指定应用的已安装应用，分别返回必需依赖和可选依赖的应用ID
+func FindDependents(appId string) ([]string, []string) {
+	required, optional := []string{}, []string{}
+	for _, app := range NewApps(nil) {
+		if app.ID == appId || app.Config.Status != "installed" {
+			continue
+		}
+		for _, require := range app.Requires {
+			if require.App != appId {
+				continue
+			}
+			if require.Optional {
+				optional = append(optional, app.ID)
+			} else {
+				required = append(required, app.ID)
+			}
+			break
+		}
+	}
+	return required, optional
+}
+
+// checkDependents 检查安装的版本是否满足已安装的其他应用的依赖版本范围
+func checkDependents(appId, version string) (string, error) {
+	for _, app := range NewApps(nil) {
+		if app.ID == appId || app.Config.Status != "installed" {
+			continue
+		}
+		for _, require := range app.Requires {
+			if require.App == appId && !utils.CheckVersionConstraint(version, require.Version) {
+				message := i18n.T("DependentVersionConflict", map[string]interface{}{
+					"version":   version,
+					"range":     require.Version,
+					"dependent": app.ID,
+				})
+				return message, errors.New(message)
+			}
+		}
+	}
+	return "", nil
+}
+
+// requiresInstalling 正在安装依赖的应用（应用本身尚未开始安装）
+var requiresInstalling sync.Map
+
+// installRequires 依次安装缺少的依赖（等待每个依赖安装完成），最后安装应用本身
+// - 应用本身开始安装（状态变为 installing）后才清除 requiresInstalling，期间其他安装请求会被拒绝
+func installRequires(req AppInternalInstallRequest, steps []requireInstallStep) {
+	defer requiresInstalling.Delete(req.AppID)
+	for _, step := range steps {
+		AppLogInfo(req.AppID, fmt.Sprintf("[Requires] installing %s %s...", step.AppID, step.Version))
+		version, stderr, err := InstallApp(&AppInternalInstallRequest{
+			AppID:   step.AppID,
+			Version: step.Version,
+			Params:  step.Params,
+		})
+		if version == "" {
+			if err != nil {
+				stderr = fmt.Sprintf("%s: %v", stderr, err)
+			}
+			AppLogError(req.AppID, fmt.Sprintf("[Requires] install %s failed: %s", step.AppID, stderr))
+			setRequiresFailed(req.AppID, i18n.T("RequireInstallFailed", map[string]interface{}{
+				"require": step.AppID,
+				"error":   stderr,
+			}))
+			return
+		}
+		if status := WaitAppFinished(step.AppID, 30*time.Minute); status != "installed" {
+			AppLogError(req.AppID, fmt.Sprintf("[Requires] install %s failed: %s", step.AppID, status))
+			message := GetAppConfig(step.AppID).Error
+			if message == "" {
+				message = status
+			}
+			setRequiresFailed(req.AppID, i18n.T("RequireInstallFailed", map[string]interface{}{
+				"require": step.AppID,
+				"error":   message,
+			}))
+			return
+		}
+		AppLogInfo(req.AppID, fmt.Sprintf("[Requires] install %s %s successful", step.AppID, version))
+	}
+
+	if version, stderr, err := installApp(&req, true); version == "" {
+		if err != nil {
+			stderr = fmt.Sprintf("%s: %v", stderr, err)
+		}
+		AppLogError(req.AppID, "[Requires] install failed: "+stderr)
+		setRequiresFailed(req.AppID, stderr)
+	}
+}
+
+// setRequiresFailed 记录依赖安装失败（或随后应用本身未能开始安装）的原因
+// - 未安装的应用标记为错误；已安装的应用（升级）保持原版本运行，只记录原因
+func setRequiresFailed(appId, message string) {
+	appConfig := GetAppConfig(appId)
+	if appConfig.Status != "installed" {
+		appConfig.Status = "error"
+	}
+	appConfig.Error = message
+	if err := os.MkdirAll(filepath.Join(global.WorkDir, "config", appId), 0755); err != nil {
+		AppLogError(appId, "Failed to create config directory: "+err.Error())
+		return
+	}
+	if err := SaveAppConfig(appId, appConfig); err != nil {
+		AppLogError(appId, "Failed to save app status: "+err.Error())
+	}
+}
+
+// CurrentAppStatus 应用当前的安装状态，正在安装依赖时为 installing
+func CurrentAppStatus(appId string) string {
+	if _, pending := requiresInstalling.Load(appId); pending {
+		return "installing"
+	}
+	return GetAppConfig(appId).Status
+}
+
+// WaitAppFinished 等待应用安装或卸载完成（包括先安装的依赖），返回最终状态，超时返回当前状态
+func WaitAppFinished(appId string, timeout time.Duration) string {
+	deadline := time.Now().Add(timeout)
+	for {
+		status := CurrentAppStatus(appId)
+		if (status != "installing" && status != "uninstalling") || time.Now().After(deadline) {
+			return status
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
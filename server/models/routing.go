@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"appstore/server/global"
+	"appstore/server/i18n"
+)
+
+// 路由模式
+const (
+	RoutingPath      = "path"      // 路径前缀（nginx.conf 的 location 包含在 DooTask 的 server 块中）
+	RoutingSubdomain = "subdomain" // 子域名（生成 <应用ID>.<基础域名> 的 server 块）
+)
+
+// RoutingModes 支持的路由模式
+var RoutingModes = []string{RoutingPath, RoutingSubdomain}
+
+// absoluteURLRegex 带协议的地址
+var absoluteURLRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://`)
+
+// dnsLabelRegex 域名中的一级标签
+var dnsLabelRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// IsSubdomainRouting 是否使用子域名路由
+func (a *App) IsSubdomainRouting() bool {
+	return a.Routing == RoutingSubdomain
+}
+
+// AppHost 子域名路由的访问域名，例如 okr.apps.example.com，未配置基础域名或应用ID不能作为子域名时返回错误
+func AppHost(appId string) (string, error) {
+	if global.AppDomain == "" {
+		return "", errors.New(i18n.T("AppDomainNotConfigured", appId))
+	}
+	label, ok := appHostLabel(appId)
+	if !ok {
+		return "", errors.New(i18n.T("AppHostInvalid", appId))
+	}
+	return label + "." + strings.TrimPrefix(global.AppDomain, "."), nil
+}
+
+// appHostLabel 应用ID对应的子域名，_ 替换为 -（例如 owner_repo => owner-repo），不是有效的域名标签时返回 false
+func appHostLabel(appId string) (string, bool) {
+	label := strings.ToLower(strings.ReplaceAll(appId, "_", "-"))
+	return label, dnsLabelRegex.MatchString(label)
+}
+
+// checkNginxServerInclude 检查 DooTask 的 nginx 是否在 http 块中包含应用的 server.conf
+// - server.conf 没有被包含时 nginx -t 也能通过，子域名路由会静默失效
+// - 通过 nginx -T 输出的配置查找 server.conf（include 指令或已加载的文件）
+func checkNginxServerInclude() error {
+	out, err := execNginx("nginx -T", 1)
+	if err != nil {
+		return errors.New(i18n.T("NginxConfigTestFailed", nginxErrorText(out, err)))
+	}
+	if !strings.Contains(out, "server.conf") {
+		return errors.New(i18n.T("NginxServerIncludeMissing"))
+	}
+	return nil
+}
+
+// appBaseURL 子域名路由的访问地址，例如 https://okr.apps.example.com
+func appBaseURL(host string) string {
+	if global.AppTLSCert != "" {
+		return "https://" + host
+	}
+	return "http://" + host
+}
+
+// rewriteMenuURL 子域名路由时将菜单的相对地址改写为子域名地址，例如 list => https://okr.apps.example.com/list
+func rewriteMenuURL(host, url string) string {
+	if url == "" || absoluteURLRegex.MatchString(url) {
+		return url
+	}
+	return appBaseURL(host) + "/" + strings.TrimPrefix(url, "/")
+}
+
+// renderNginxServer 将应用的 location 配置包装为子域名的 server 块
+// - 配置了证书时监听443端口，80端口重定向到https
+func renderNginxServer(host, locations string) string {
+	var builder strings.Builder
+	lines := strings.Split(strings.TrimRight(locations, "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = "    " + line
+		}
+	}
+	body := strings.Join(lines, "\n")
+
+	if global.AppTLSCert != "" {
+		fmt.Fprintf(&builder, "server {\n    listen 80;\n    server_name %s;\n    return 301 https://$host$request_uri;\n}\n\n", host)
+		fmt.Fprintf(&builder, "server {\n    listen 443 ssl;\n    server_name %s;\n", host)
+		fmt.Fprintf(&builder, "    ssl_certificate %s;\n    ssl_certificate_key %s;\n\n", global.AppTLSCert, global.AppTLSKey)
+	} else {
+		fmt.Fprintf(&builder, "server {\n    listen 80;\n    server_name %s;\n\n", host)
+	}
+	builder.WriteString(body)
+	builder.WriteString("\n}\n")
+	return builder.String()
+}
@@ -0,0 +1,292 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"appstore/server/global"
+	"appstore/server/utils"
+)
+
+// 应用运行状态
+const (
+	RuntimeHealthy   = "healthy"   // 所有服务运行中且健康（正常退出的一次性容器除外）
+	RuntimeUnhealthy = "unhealthy" // 部分服务未运行、异常退出或不健康
+	RuntimeStopped   = "stopped"   // 没有运行中的容器
+	RuntimeUnknown   = "unknown"   // 无法获取容器状态
+)
+
+// runtimeCacheTTL 运行状态的缓存时间（容器事件会使缓存失效）
+const runtimeCacheTTL = 5 * time.Second
+
+// runtimeQueryTimeout 获取运行状态的超时时间
+const runtimeQueryTimeout = 10 * time.Second
+
+// ServiceRuntime 服务的运行状态
+type ServiceRuntime struct {
+	Service      string `json:"service"`
+	Container    string `json:"container"`        // 容器名称，容器不存在时为空
+	State        string `json:"state"`            // running、exited、restarting、paused、created，容器不存在时为 missing
+	Health       string `json:"health,omitempty"` // healthy、unhealthy、starting，未配置 healthcheck 时为空
+	ExitCode     int    `json:"exit_code"`        // 最近一次退出码
+	RestartCount int    `json:"restart_count"`    // Docker 重启容器的次数
+	StartedAt    string `json:"started_at"`       // 最近一次启动时间
+	Uptime       int64  `json:"uptime"`           // 运行时长（秒），未运行时为0
+	Image        string `json:"image"`            // 镜像，例如 nginx:1.25
+	Digest       string `json:"digest,omitempty"` // 镜像摘要，例如 sha256:...（本地构建的镜像为镜像ID）
+}
+
+// AppRuntime 应用的运行状态（从容器运行时获取）
+type AppRuntime struct {
+	Status    string           `json:"status"`             // healthy、unhealthy、stopped、unknown
+	Degraded  string           `json:"degraded,omitempty"` // 容器反复崩溃的原因（容器守护已停止自动重启）
+	Services  []ServiceRuntime `json:"services"`
+	UpdatedAt string           `json:"updated_at"`
+}
+
+// ServiceState 服务的公开运行状态（不包含容器名称、镜像及摘要等信息）
+type ServiceState struct {
+	Service string `json:"service"`
+	State   string `json:"state"`            // running、exited、restarting、paused、created，容器不存在时为 missing
+	Health  string `json:"health,omitempty"` // healthy、unhealthy、starting，未配置 healthcheck 时为空
+}
+
+// AppRuntimeState 应用的公开运行状态（应用详情使用，只包含状态和健康）
+type AppRuntimeState struct {
+	Status   string         `json:"status"` // healthy、unhealthy、stopped、unknown
+	Services []ServiceState `json:"services"`
+}
+
+// PublicState 运行状态中可以公开的部分
+func (r *AppRuntime) PublicState() *AppRuntimeState {
+	state := &AppRuntimeState{
+		Status:   r.Status,
+		Services: make([]ServiceState, 0, len(r.Services)),
+	}
+	for _, service := range r.Services {
+		state.Services = append(state.Services, ServiceState{
+			Service: service.Service,
+			State:   service.State,
+			Health:  service.Health,
+		})
+	}
+	return state
+}
+
+// dockerInspect docker inspect 输出的容器信息
+type dockerInspect struct {
+	Name         string `json:"Name"`
+	Image        string `json:"Image"` // 镜像ID
+	RestartCount int    `json:"RestartCount"`
+	State        struct {
+		Status    string `json:"Status"`
+		ExitCode  int    `json:"ExitCode"`
+		StartedAt string `json:"StartedAt"`
+		Health    *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+	Config struct {
+		Image string `json:"Image"`
+	} `json:"Config"`
+}
+
+var (
+	runtimeCache   = map[string]runtimeCacheItem{}
+	runtimeCacheMu sync.Mutex
+)
+
+type runtimeCacheItem struct {
+	runtime *AppRuntime
+	expires time.Time
+}
+
+// GetAppRuntime 获取应用的运行状态（缓存5秒），应用未安装时返回 nil
+func GetAppRuntime(appId string) *AppRuntime {
+	appConfig := GetAppConfig(appId)
+	if appConfig.Status == "not_installed" || !utils.IsFileExists(filepath.Join(global.WorkDir, "config", appId, "docker-compose.yml")) {
+		return nil
+	}
+
+	runtimeCacheMu.Lock()
+	item, ok := runtimeCache[appId]
+	runtimeCacheMu.Unlock()
+	if !ok || time.Now().After(item.expires) {
+		ctx, cancel := context.WithTimeout(context.Background(), runtimeQueryTimeout)
+		item = runtimeCacheItem{runtime: queryAppRuntime(ctx, appId), expires: time.Now().Add(runtimeCacheTTL)}
+		cancel()
+		runtimeCacheMu.Lock()
+		runtimeCache[appId] = item
+		runtimeCacheMu.Unlock()
+	}
+
+	// 降级状态以应用配置为准，不缓存
+	runtime := *item.runtime
+	runtime.Degraded = appConfig.Degraded
+	return &runtime
+}
+
+// GetAppsRuntime 并发获取多个应用的运行状态（按应用ID）
+func GetAppsRuntime(appIds []string) map[string]*AppRuntime {
+	runtimes := make(map[string]*AppRuntime, len(appIds))
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, appId := range appIds {
+		wg.Add(1)
+		go func(appId string) {
+			defer wg.Done()
+			runtime := GetAppRuntime(appId)
+			mu.Lock()
+			runtimes[appId] = runtime
+			mu.Unlock()
+		}(appId)
+	}
+	wg.Wait()
+	return runtimes
+}
+
+// invalidateAppRuntime 清除应用运行状态的缓存
+func invalidateAppRuntime(appId string) {
+	runtimeCacheMu.Lock()
+	delete(runtimeCache, appId)
+	runtimeCacheMu.Unlock()
+}
+
+// queryAppRuntime 从容器运行时获取应用各服务的运行状态
+func queryAppRuntime(ctx context.Context, appId string) *AppRuntime {
+	runtime := &AppRuntime{
+		Status:    RuntimeUnknown,
+		Services:  []ServiceRuntime{},
+		UpdatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	containers, err := composeContainers(ctx, appId)
+	if err != nil {
+		return runtime
+	}
+
+	// 容器详情
+	inspects := map[string]dockerInspect{}
+	if len(containers) > 0 {
+		args := []string{"inspect"}
+		for _, container := range containers {
+			args = append(args, container.Name)
+		}
+		output, err := exec.CommandContext(ctx, "docker", args...).Output()
+		if err != nil {
+			return runtime
+		}
+		items := []dockerInspect{}
+		if err := json.Unmarshal(output, &items); err != nil {
+			return runtime
+		}
+		for _, item := range items {
+			inspects[strings.TrimPrefix(item.Name, "/")] = item
+		}
+	}
+	digests := imageDigests(ctx, inspects)
+
+	// 各服务状态（声明了但没有容器的服务为 missing）
+	services := map[string]bool{}
+	now := time.Now()
+	for _, container := range containers {
+		services[container.Service] = true
+		service := ServiceRuntime{
+			Service:   container.Service,
+			Container: container.Name,
+			State:     container.State,
+			Health:    container.Health,
+			ExitCode:  container.ExitCode,
+		}
+		if inspect, ok := inspects[container.Name]; ok {
+			service.State = inspect.State.Status
+			service.ExitCode = inspect.State.ExitCode
+			service.RestartCount = inspect.RestartCount
+			service.Image = inspect.Config.Image
+			service.Digest = digests[inspect.Image]
+			if inspect.State.Health != nil {
+				service.Health = inspect.State.Health.Status
+			}
+			if startedAt, err := time.Parse(time.RFC3339Nano, inspect.State.StartedAt); err == nil && startedAt.Year() > 1 {
+				service.StartedAt = startedAt.Local().Format("2006-01-02 15:04:05")
+				if service.State == "running" {
+					service.Uptime = int64(now.Sub(startedAt).Seconds())
+				}
+			}
+		}
+		runtime.Services = append(runtime.Services, service)
+	}
+	for service := range composeServiceHosts(appId) {
+		if !services[service] {
+			runtime.Services = append(runtime.Services, ServiceRuntime{Service: service, State: "missing"})
+		}
+	}
+	sort.Slice(runtime.Services, func(i, j int) bool {
+		if runtime.Services[i].Service != runtime.Services[j].Service {
+			return runtime.Services[i].Service < runtime.Services[j].Service
+		}
+		return runtime.Services[i].Container < runtime.Services[j].Container
+	})
+
+	runtime.Status = runtimeStatus(runtime.Services)
+	return runtime
+}
+
+// runtimeStatus 根据各服务状态计算应用运行状态
+func runtimeStatus(services []ServiceRuntime) string {
+	running, failed := 0, 0
+	for _, service := range services {
+		switch {
+		case service.State == "running" && service.Health != "unhealthy":
+			running++
+		case service.State == "exited" && service.ExitCode == 0:
+			// 正常退出的一次性容器
+		default:
+			failed++
+		}
+	}
+	switch {
+	case running == 0:
+		return RuntimeStopped
+	case failed > 0:
+		return RuntimeUnhealthy
+	default:
+		return RuntimeHealthy
+	}
+}
+
+// imageDigests 容器使用的镜像摘要（按镜像ID），没有仓库摘要的本地镜像使用镜像ID
+func imageDigests(ctx context.Context, inspects map[string]dockerInspect) map[string]string {
+	digests := map[string]string{}
+	args := []string{"image", "inspect", "--format", "{{.Id}} {{range .RepoDigests}}{{.}} {{end}}"}
+	for _, inspect := range inspects {
+		if inspect.Image != "" && !slices.Contains(args[4:], inspect.Image) {
+			args = append(args, inspect.Image)
+		}
+	}
+	if len(args) == 4 {
+		return digests
+	}
+	output, _ := exec.CommandContext(ctx, "docker", args...).Output()
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		digests[fields[0]] = fields[0]
+		if len(fields) > 1 {
+			if _, digest, ok := strings.Cut(fields[1], "@"); ok {
+				digests[fields[0]] = digest
+			}
+		}
+	}
+	return digests
+}
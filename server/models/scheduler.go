@@ -0,0 +1,227 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"appstore/server/global"
+)
+
+// sourcesUpdateMaxRecords 最多保留的更新记录数
+const sourcesUpdateMaxRecords = 20
+
+var sourcesUpdateMutex sync.RWMutex // 更新记录读写锁
+
+// UpgradeableApp 可升级的已安装应用
+type UpgradeableApp struct {
+	ID               string `json:"id"`
+	InstalledVersion string `json:"installed_version"`
+	LatestVersion    string `json:"latest_version"`
+}
+
+// SourcesUpdateRecord 一次后台更新应用列表的记录
+type SourcesUpdateRecord struct {
+	StartedAt      string             `json:"started_at"`
+	FinishedAt     string             `json:"finished_at"`
+	Error          string             `json:"error,omitempty"`
+	Result         *SourcesSyncResult `json:"result,omitempty"`
+	Upgradeable    []UpgradeableApp   `json:"upgradeable"`     // 当前可升级的已安装应用
+	NewUpgradeable []UpgradeableApp   `json:"new_upgradeable"` // 本次更新后新增的可升级应用
+}
+
+// SourcesUpdateStatus 后台更新应用列表状态
+type SourcesUpdateStatus struct {
+	Interval string                 `json:"interval"` // 更新间隔，0 表示不自动更新
+	Window   string                 `json:"window"`   // 允许更新的时间段，为空表示不限制
+	Records  []*SourcesUpdateRecord `json:"records"`  // 最近的更新记录（新的在前）
+}
+
+// sourcesUpdateRecordsFile 更新记录文件路径
+func sourcesUpdateRecordsFile() string {
+	return filepath.Join(global.WorkDir, "config", "sources_update.json")
+}
+
+// ParseUpdateWindow 解析更新时间段（例如 02:00-05:00，支持跨天 22:00-04:00）
+// 返回开始和结束的分钟数，时间段为空时返回 -1
+func ParseUpdateWindow(window string) (int, int, error) {
+	if window == "" {
+		return -1, -1, nil
+	}
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid update window: %s", window)
+	}
+	minutes := make([]int, 2)
+	for i, part := range parts {
+		hm := strings.Split(strings.TrimSpace(part), ":")
+		if len(hm) != 2 {
+			return 0, 0, fmt.Errorf("invalid update window: %s", window)
+		}
+		hour, err1 := strconv.Atoi(hm[0])
+		minute, err2 := strconv.Atoi(hm[1])
+		if err1 != nil || err2 != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+			return 0, 0, fmt.Errorf("invalid update window: %s", window)
+		}
+		minutes[i] = hour*60 + minute
+	}
+	if minutes[0] == minutes[1] {
+		return 0, 0, errors.New("update window start and end cannot be the same")
+	}
+	return minutes[0], minutes[1], nil
+}
+
+// InUpdateWindow 判断时间是否在更新时间段内
+func InUpdateWindow(window string, t time.Time) bool {
+	start, end, err := ParseUpdateWindow(window)
+	if err != nil {
+		return false
+	}
+	if start < 0 {
+		return true
+	}
+	now := t.Hour()*60 + t.Minute()
+	if start < end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// FindUpgradeableApps 获取可升级的已安装应用
+func FindUpgradeableApps() []UpgradeableApp {
+	upgradeable := []UpgradeableApp{}
+	for _, app := range NewApps(nil) {
+		if !app.Upgradeable {
+			continue
+		}
+		// 按发布通道、版本范围、固定版本和兼容性过滤后的最新版本
+		latest, err := FindLatestVersion(app.ID)
+		if err != nil || latest == app.Config.InstallVersion {
+			continue
+		}
+		upgradeable = append(upgradeable, UpgradeableApp{
+			ID:               app.ID,
+			InstalledVersion: app.Config.InstallVersion,
+			LatestVersion:    latest,
+		})
+	}
+	return upgradeable
+}
+
+// GetSourcesUpdateRecords 获取后台更新记录（新的在前）
+func GetSourcesUpdateRecords() []*SourcesUpdateRecord {
+	sourcesUpdateMutex.RLock()
+	defer sourcesUpdateMutex.RUnlock()
+
+	records := []*SourcesUpdateRecord{}
+	if data, err := os.ReadFile(sourcesUpdateRecordsFile()); err == nil {
+		_ = json.Unmarshal(data, &records)
+	}
+	return records
+}
+
+// GetSourcesUpdateStatus 获取后台更新应用列表状态
+func GetSourcesUpdateStatus() *SourcesUpdateStatus {
+	return &SourcesUpdateStatus{
+		Interval: global.UpdateInterval.String(),
+		Window:   global.UpdateWindow,
+		Records:  GetSourcesUpdateRecords(),
+	}
+}
+
+// saveSourcesUpdateRecord 保存后台更新记录
+func saveSourcesUpdateRecord(record *SourcesUpdateRecord) error {
+	records := GetSourcesUpdateRecords()
+
+	sourcesUpdateMutex.Lock()
+	defer sourcesUpdateMutex.Unlock()
+
+	records = append([]*SourcesUpdateRecord{record}, records...)
+	if len(records) > sourcesUpdateMaxRecords {
+		records = records[:sourcesUpdateMaxRecords]
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(sourcesUpdateRecordsFile(), data, 0644)
+}
+
+// RunSourcesUpdate 执行一次后台更新应用列表并记录结果
+// - 本地修改和非仓库来源的应用不会被覆盖
+// - 对比上一次记录，找出新增的可升级应用
+func RunSourcesUpdate() *SourcesUpdateRecord {
+	record := &SourcesUpdateRecord{
+		StartedAt:      time.Now().Format("2006-01-02 15:04:05"),
+		NewUpgradeable: []UpgradeableApp{},
+	}
+
+	result, stderr, err := SyncSources(false, nil)
+	if result == nil {
+		record.Error = stderr
+		if err != nil {
+			record.Error = fmt.Sprintf("%s: %v", stderr, err)
+		}
+	}
+	record.Result = result
+
+	// 新增的可升级应用
+	previous := []string{}
+	if records := GetSourcesUpdateRecords(); len(records) > 0 {
+		for _, app := range records[0].Upgradeable {
+			previous = append(previous, app.ID+"@"+app.LatestVersion)
+		}
+	}
+	record.Upgradeable = FindUpgradeableApps()
+	for _, app := range record.Upgradeable {
+		if !slices.Contains(previous, app.ID+"@"+app.LatestVersion) {
+			record.NewUpgradeable = append(record.NewUpgradeable, app)
+		}
+	}
+	record.FinishedAt = time.Now().Format("2006-01-02 15:04:05")
+
+	if err := saveSourcesUpdateRecord(record); err != nil {
+		fmt.Printf("[Scheduler] Failed to save update record: %v\n", err)
+	}
+	return record
+}
+
+// StartSourcesUpdateScheduler 启动后台定时更新应用列表
+// - 间隔为 0 时不启动
+// - 每分钟检查一次，距离上次更新超过间隔且在允许的时间段内时执行
+// - 上次更新时间取自更新记录，重启后不会立即重复更新
+func StartSourcesUpdateScheduler() {
+	if global.UpdateInterval <= 0 {
+		return
+	}
+
+	var lastRun time.Time
+	if records := GetSourcesUpdateRecords(); len(records) > 0 {
+		lastRun, _ = time.ParseInLocation("2006-01-02 15:04:05", records[0].StartedAt, time.Local)
+	}
+
+	for {
+		now := time.Now()
+		if now.Sub(lastRun) >= global.UpdateInterval && InUpdateWindow(global.UpdateWindow, now) {
+			lastRun = now
+			record := RunSourcesUpdate()
+			if record.Error != "" {
+				fmt.Printf("[Scheduler] Update sources failed: %s\n", record.Error)
+			} else {
+				fmt.Printf("[Scheduler] Update sources finished: %d updated, %d failed, %d skipped\n", len(record.Result.Success), len(record.Result.Failed), len(record.Result.Skipped))
+			}
+			for _, app := range record.NewUpgradeable {
+				fmt.Printf("[Scheduler] %s can be upgraded from %s to %s\n", app.ID, app.InstalledVersion, app.LatestVersion)
+				AppLogInfo(app.ID, fmt.Sprintf("[Scheduler] new version %s available (installed %s)", app.LatestVersion, app.InstalledVersion))
+			}
+		}
+		time.Sleep(time.Minute)
+	}
+}
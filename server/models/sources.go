@@ -0,0 +1,747 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"appstore/server/global"
+	"appstore/server/i18n"
+	"appstore/server/utils"
+)
+
+// SourcesIndexFormat 仓库索引格式版本
+const SourcesIndexFormat = 1
+
+var (
+	SourcesServer = "https://appstore.dootask.com"          // 应用商店源服务器
+	SourcesClient = &http.Client{Timeout: 10 * time.Minute} // 下载源使用的客户端
+
+	sourcesSyncMutex sync.Mutex // 同步应用源锁
+)
+
+// SourcesIndex 仓库索引
+type SourcesIndex struct {
+	Format      int                         `json:"format"`
+	GeneratedAt string                      `json:"generated_at"`
+	Apps        map[string]*SourcesIndexApp `json:"apps"`
+}
+
+// SourcesIndexApp 仓库索引中的应用
+type SourcesIndexApp struct {
+	LatestVersion string                          `json:"latest_version"`
+	Digest        string                          `json:"digest"`
+	Size          int64                           `json:"size"`
+	UpdatedAt     string                          `json:"updated_at"`
+	Versions      map[string]*SourcesIndexVersion `json:"versions"`
+}
+
+// SourcesIndexVersion 仓库索引中的应用版本
+type SourcesIndexVersion struct {
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// 应用源差异状态
+const (
+	SourcesDiffNew       = "new"       // 本地不存在
+	SourcesDiffUnchanged = "unchanged" // 没有变化
+	SourcesDiffUpdated   = "updated"   // 远程有更新
+	SourcesDiffModified  = "modified"  // 本地在上次同步后被修改，或来源未知且内容与仓库不一致
+	SourcesDiffForeign   = "foreign"   // 本地应用不是来自应用商店仓库（上传、URL、Git）
+)
+
+// SourcesDiffItem 单个应用的同步差异
+type SourcesDiffItem struct {
+	ID              string   `json:"id"`
+	Status          string   `json:"status"` // new, unchanged, updated, modified, foreign
+	Source          string   `json:"source"` // 本地应用包来源：repo, url, git, upload（未记录时为空）
+	LocalVersion    string   `json:"local_version"`
+	RemoteVersion   string   `json:"remote_version"`
+	AddedVersions   []string `json:"added_versions"`
+	RemovedVersions []string `json:"removed_versions"`
+	ChangedVersions []string `json:"changed_versions"`
+	Result          string   `json:"result,omitempty"` // success, failed, skipped, unchanged（试运行时为空）
+	Reason          string   `json:"reason,omitempty"`
+}
+
+// SourcesSyncResult 同步应用源结果
+type SourcesSyncResult struct {
+	DryRun    bool                `json:"dry_run"`
+	Apps      []*SourcesDiffItem  `json:"apps"`
+	Success   []map[string]string `json:"success"`
+	Failed    []map[string]string `json:"failed"`
+	Skipped   []map[string]string `json:"skipped"`
+	Unchanged []map[string]string `json:"unchanged"`
+	Upgrades  []UpgradeDecision   `json:"upgrades"` // 同步后自动升级策略的评估结果
+}
+
+// sourcesSyncState 上一次同步的状态
+type sourcesSyncState struct {
+	ETag     string        `json:"etag"`
+	Index    *SourcesIndex `json:"index"`
+	Migrated bool          `json:"migrated"` // 已为记录来源之前的应用补充来源
+}
+
+// ETag 根据所有应用摘要生成索引的ETag
+func (idx *SourcesIndex) ETag() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%d\n", idx.Format)
+	for _, appId := range sortedIndexAppIds(idx) {
+		fmt.Fprintf(&builder, "%s %s\n", appId, idx.Apps[appId].Digest)
+	}
+	return fmt.Sprintf("\"%s%s\"", utils.DigestPrefix, utils.SHA256(builder.String()))
+}
+
+// sortedIndexAppIds 返回排序后的索引应用ID
+func sortedIndexAppIds(idx *SourcesIndex) []string {
+	appIds := make([]string, 0, len(idx.Apps))
+	for appId := range idx.Apps {
+		appIds = append(appIds, appId)
+	}
+	sort.Strings(appIds)
+	return appIds
+}
+
+// buildSourcesIndex 根据应用目录生成仓库索引
+func buildSourcesIndex(appsDir string) (*SourcesIndex, error) {
+	appIds, err := utils.GetSubDirs(appsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &SourcesIndex{
+		Format:      SourcesIndexFormat,
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Apps:        make(map[string]*SourcesIndexApp),
+	}
+	for _, appId := range appIds {
+		// 跳过隐藏目录和没有配置文件的目录
+		if strings.HasPrefix(appId, ".") || !utils.IsFileExists(filepath.Join(appsDir, appId, "config.yml")) {
+			continue
+		}
+
+		appDigest, err := utils.DirDigest(filepath.Join(appsDir, appId), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		indexApp := &SourcesIndexApp{
+			Digest:    appDigest.Digest,
+			Size:      appDigest.Size,
+			UpdatedAt: appDigest.UpdatedAt.Format(time.RFC3339),
+			Versions:  make(map[string]*SourcesIndexVersion),
+		}
+		for i, version := range findDirVersions(filepath.Join(appsDir, appId)) {
+			if i == 0 {
+				indexApp.LatestVersion = version
+			}
+			versionDigest, err := utils.DirDigest(filepath.Join(appsDir, appId, version), nil)
+			if err != nil {
+				return nil, err
+			}
+			indexApp.Versions[version] = &SourcesIndexVersion{
+				Digest:    versionDigest.Digest,
+				Size:      versionDigest.Size,
+				UpdatedAt: versionDigest.UpdatedAt.Format(time.RFC3339),
+			}
+		}
+		index.Apps[appId] = indexApp
+	}
+
+	return index, nil
+}
+
+// CheckSourceConfig 检查应用源目录中的config.yml，返回失败原因（为空表示通过）
+func CheckSourceConfig(appId, sourceDir string) string {
+	configFile := filepath.Join(sourceDir, "config.yml")
+	if !utils.IsFileExists(configFile) {
+		return i18n.T("ConfigYmlNotFound")
+	}
+
+	configData, err := os.ReadFile(configFile)
+	if err != nil {
+		return i18n.T("ReadConfigFailed", err.Error())
+	}
+
+	return ValidateManifest(appId, configData)
+}
+
+// SyncSources 从远程仓库增量同步应用源
+// 1、带 If-None-Match 下载仓库索引，未变化则使用上次同步的索引
+// 2、对比本地应用与索引，计算每个应用的差异（新增、无变化、更新、本地修改、非仓库来源），第一次同步时为记录来源之前的应用补充仓库来源（参见 findLegacyAppSources）
+// 3、试运行时直接返回差异
+// 4、本地修改和非仓库来源的应用默认跳过，overwrite 中指定的应用（* 表示全部）才会覆盖
+// 5、只下载有差异的应用压缩包，校验摘要与索引一致后替换apps目录中的应用（删除上游已移除的版本和文件，已安装版本的目录保持不变），并记录来源
+// 6、记录同步状态，供下次条件请求使用
+// 7、评估已安装应用的自动升级策略
+// 8、返回同步结果（第一个参数不为空表示成功）
+func SyncSources(dryRun bool, overwrite []string) (*SourcesSyncResult, string, error) {
+	// 同一时间只允许一个同步任务，不阻塞请求
+	if !sourcesSyncMutex.TryLock() {
+		return nil, i18n.T("SourcesSyncRunning"), nil
+	}
+	defer sourcesSyncMutex.Unlock()
+
+	tempDir := filepath.Join(global.WorkDir, "temp", "sources")
+	stateFile := filepath.Join(global.WorkDir, "config", "sources.json")
+
+	// 读取上一次同步状态
+	state := &sourcesSyncState{}
+	if data, err := os.ReadFile(stateFile); err == nil {
+		_ = json.Unmarshal(data, state)
+	}
+
+	// 清空临时目录
+	if utils.IsDirExists(tempDir) {
+		if err := os.RemoveAll(tempDir); err != nil {
+			return nil, i18n.T("CleanTempDirFailed"), err
+		}
+	}
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return nil, i18n.T("CreateTempDirFailed"), err
+	}
+	defer os.RemoveAll(tempDir)
+
+	// 下载仓库索引，上游不支持索引（旧版本）时下载完整资源包并在本地生成索引
+	packageDir := ""
+	index, etag, stderr, err := fetchSourcesIndex(state)
+	if errors.Is(err, errSourcesIndexNotFound) {
+		packageDir = filepath.Join(tempDir, "package")
+		index, stderr, err = fetchSourcesPackage(packageDir)
+	}
+	if index == nil {
+		return nil, stderr, err
+	}
+
+	results := &SourcesSyncResult{
+		DryRun:    dryRun,
+		Apps:      make([]*SourcesDiffItem, 0),
+		Success:   make([]map[string]string, 0),
+		Failed:    make([]map[string]string, 0),
+		Skipped:   make([]map[string]string, 0),
+		Unchanged: make([]map[string]string, 0),
+		Upgrades:  make([]UpgradeDecision, 0),
+	}
+
+	// 补充记录来源之前的应用的来源（只执行一次，试运行时不写入）
+	legacySources := map[string]*AppSource{}
+	if !state.Migrated {
+		legacySources = findLegacyAppSources(state.Index, index)
+		if !dryRun {
+			for appId, source := range legacySources {
+				if err := SaveAppSource(appId, source); err != nil {
+					return nil, i18n.T("SaveAppSourceFailed"), err
+				}
+			}
+			state.Migrated = true
+		}
+	}
+
+	// 计算差异
+	diffs := make([]*SourcesDiffItem, 0, len(index.Apps))
+	for _, appId := range sortedIndexAppIds(index) {
+		source := GetAppSource(appId)
+		if source == nil {
+			source = legacySources[appId]
+		}
+		diffs = append(diffs, diffSourceApp(appId, index.Apps[appId], source))
+	}
+	results.Apps = diffs
+	if dryRun {
+		return results, "", nil
+	}
+
+	for _, item := range diffs {
+		indexApp := index.Apps[item.ID]
+
+		// 本地修改或非仓库来源的应用需要明确指定才覆盖
+		if item.Reason == "" && (item.Status == SourcesDiffModified || item.Status == SourcesDiffForeign) {
+			if !slices.Contains(overwrite, item.ID) && !slices.Contains(overwrite, "*") {
+				reason := i18n.T("AppSourceModifiedSkipped")
+				if item.Status == SourcesDiffForeign {
+					reason = i18n.T("AppSourceForeignSkipped", item.Source)
+				} else if item.Source == "" {
+					reason = i18n.T("AppSourceUnknownSkipped")
+				}
+				item.Result = "skipped"
+				item.Reason = reason
+				results.Skipped = append(results.Skipped, map[string]string{
+					"id":     item.ID,
+					"reason": reason,
+				})
+				continue
+			}
+		}
+
+		if item.Reason == "" && item.Status != SourcesDiffUnchanged {
+			item.Reason = syncSourceApp(item.ID, indexApp, tempDir, packageDir)
+		}
+
+		switch {
+		case item.Reason != "":
+			item.Result = "failed"
+			results.Failed = append(results.Failed, map[string]string{
+				"id":     item.ID,
+				"reason": item.Reason,
+			})
+			continue
+		case item.Status == SourcesDiffUnchanged:
+			item.Result = "unchanged"
+			results.Unchanged = append(results.Unchanged, map[string]string{
+				"id": item.ID,
+			})
+		default:
+			item.Result = "success"
+			results.Success = append(results.Success, map[string]string{
+				"id": item.ID,
+			})
+		}
+
+		// 记录来源（未记录来源且内容与仓库一致的应用也开始跟踪，补充的来源更新为当前索引的摘要）
+		if source := GetAppSource(item.ID); item.Status != SourcesDiffUnchanged || source == nil || source.RemoteDigest != indexApp.Digest {
+			_ = SaveAppSource(item.ID, &AppSource{
+				Type:         AppSourceRepo,
+				URL:          SourcesServer,
+				RemoteDigest: indexApp.Digest,
+			})
+		}
+	}
+
+	// 有应用更新时清除资源包缓存
+	if len(results.Success) > 0 {
+		InvalidateSourcesPackage()
+	}
+
+	// 全部成功时才记录索引ETag，否则下次重新对比
+	if len(results.Failed) == 0 {
+		state.ETag = etag
+		state.Index = index
+	}
+	if data, err := json.Marshal(state); err == nil {
+		_ = os.WriteFile(stateFile, data, 0644)
+	}
+
+	// 评估自动升级策略
+	results.Upgrades = EvaluateUpgradePolicies()
+
+	return results, "", nil
+}
+
+// errSourcesIndexNotFound 上游不支持仓库索引（旧版本的应用商店）
+var errSourcesIndexNotFound = errors.New("sources index not found")
+
+// fetchSourcesIndex 下载仓库索引，索引未变化时返回上次同步的索引，上游不支持索引时返回 errSourcesIndexNotFound
+// 返回索引、ETag（第一个参数不为空表示成功）
+func fetchSourcesIndex(state *sourcesSyncState) (*SourcesIndex, string, string, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/%s/sources/index", SourcesServer, global.APIVersion), nil)
+	if err != nil {
+		return nil, "", i18n.T("DownloadSourceListFailed"), err
+	}
+	if state.ETag != "" && state.Index != nil {
+		req.Header.Set("If-None-Match", state.ETag)
+	}
+	resp, err := SourcesClient.Do(req)
+	if err != nil {
+		return nil, "", i18n.T("DownloadSourceListFailed"), err
+	}
+	defer resp.Body.Close()
+
+	// 索引未变化
+	if resp.StatusCode == http.StatusNotModified && state.Index != nil {
+		return state.Index, state.ETag, "", nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", i18n.T("DownloadSourceListFailed"), errSourcesIndexNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", i18n.T("DownloadSourceListFailed"), fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	// 解析仓库索引
+	index := &SourcesIndex{}
+	if err := json.NewDecoder(resp.Body).Decode(index); err != nil {
+		return nil, "", i18n.T("ParseSourcesIndexFailed"), err
+	}
+	if index.Apps == nil {
+		index.Apps = make(map[string]*SourcesIndexApp)
+	}
+
+	return index, resp.Header.Get("ETag"), "", nil
+}
+
+// fetchSourcesPackage 下载完整资源包解压到 packageDir，并根据其内容生成仓库索引（用于不支持索引的上游）
+// 返回索引（第一个参数不为空表示成功）
+func fetchSourcesPackage(packageDir string) (*SourcesIndex, string, error) {
+	resp, err := SourcesClient.Get(fmt.Sprintf("%s/api/%s/sources/package", SourcesServer, global.APIVersion))
+	if err != nil {
+		return nil, i18n.T("DownloadSourceListFailed"), err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, i18n.T("DownloadSourceListFailed"), fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	// 保存并解压
+	tarFile := packageDir + ".tar.gz"
+	file, err := os.Create(tarFile)
+	if err != nil {
+		return nil, i18n.T("SaveFileFailed"), err
+	}
+	_, err = io.Copy(file, resp.Body)
+	file.Close()
+	if err != nil {
+		return nil, i18n.T("ReadDownloadDataFailed"), err
+	}
+	if err := utils.UnTarGz(tarFile, packageDir); err != nil {
+		return nil, i18n.T("ExtractFileFailed"), err
+	}
+	os.Remove(tarFile)
+
+	index, err := buildSourcesIndex(packageDir)
+	if err != nil {
+		return nil, i18n.T("ParseSourcesIndexFailed"), err
+	}
+	return index, "", nil
+}
+
+// findLegacyAppSources 查找记录来源之前的应用（没有 source.yml）并返回对应的仓库来源
+// - 没有上一次同步的索引：之前的版本每次更新都会覆盖所有应用，索引中存在的本地应用都视为仓库来源
+// - 有上一次同步的索引：版本列表相同，且除已安装版本（包含运行时数据）外各版本摘要一致的应用视为仓库来源
+func findLegacyAppSources(previous, index *SourcesIndex) map[string]*AppSource {
+	sources := map[string]*AppSource{}
+	for appId := range index.Apps {
+		appDir := filepath.Join(global.WorkDir, "apps", appId)
+		if !utils.IsDirExists(appDir) || utils.IsFileExists(appSourceFile(appId)) {
+			continue
+		}
+		remoteDigest := ""
+		if previous != nil {
+			previousApp, ok := previous.Apps[appId]
+			if !ok || !matchIndexVersions(appId, previousApp) {
+				continue
+			}
+			remoteDigest = previousApp.Digest
+		}
+		digest, err := appSourceDigest(appId)
+		if err != nil {
+			continue
+		}
+		sources[appId] = &AppSource{
+			Type:         AppSourceRepo,
+			URL:          SourcesServer,
+			Digest:       digest.Digest,
+			RemoteDigest: remoteDigest,
+		}
+	}
+	return sources
+}
+
+// matchIndexVersions 本地应用的版本列表与索引相同，且除已安装版本外各版本摘要一致
+func matchIndexVersions(appId string, indexApp *SourcesIndexApp) bool {
+	localVersions := findVersions(appId)
+	if len(localVersions) != len(indexApp.Versions) {
+		return false
+	}
+	installedVersion := appInstalledVersion(appId)
+	for _, version := range localVersions {
+		indexVersion, ok := indexApp.Versions[version]
+		if !ok {
+			return false
+		}
+		if version == installedVersion {
+			continue
+		}
+		digest, err := utils.DirDigest(filepath.Join(global.WorkDir, "apps", appId, version), nil)
+		if err != nil || digest.Digest != indexVersion.Digest {
+			return false
+		}
+	}
+	return true
+}
+
+// diffSourceApp 对比本地应用与索引中的应用，source 为本地来源（未记录时为nil）
+// - 本地与远程摘要相同：无变化
+// - 本地应用来自上传、URL或Git：非仓库来源
+// - 远程与本地都没有变化（本地仅保留了旧文件）：无变化
+// - 本地在上次同步后被修改：本地修改（不包含已安装版本运行时写入的数据）
+// - 其他情况：远程有更新
+func diffSourceApp(appId string, indexApp *SourcesIndexApp, source *AppSource) *SourcesDiffItem {
+	item := &SourcesDiffItem{
+		ID:              appId,
+		AddedVersions:   []string{},
+		RemovedVersions: []string{},
+		ChangedVersions: []string{},
+	}
+
+	// 检查应用ID
+	if appId == "" || strings.HasPrefix(appId, ".") || strings.ContainsAny(appId, "/\\") || indexApp == nil {
+		item.Status = SourcesDiffUnchanged
+		item.Reason = i18n.T("InvalidAppId")
+		return item
+	}
+	item.RemoteVersion = indexApp.LatestVersion
+
+	// 本地不存在
+	targetDir := filepath.Join(global.WorkDir, "apps", appId)
+	if !utils.IsDirExists(targetDir) {
+		item.Status = SourcesDiffNew
+		for version := range indexApp.Versions {
+			item.AddedVersions = append(item.AddedVersions, version)
+		}
+		sortVersionsDesc(item.AddedVersions)
+		return item
+	}
+
+	// 本地来源
+	if source != nil {
+		item.Source = source.Type
+	}
+
+	// 对比版本
+	localVersions := findVersions(appId)
+	if len(localVersions) > 0 {
+		item.LocalVersion = localVersions[0]
+	}
+	for version, indexVersion := range indexApp.Versions {
+		if !slices.Contains(localVersions, version) {
+			item.AddedVersions = append(item.AddedVersions, version)
+			continue
+		}
+		localVersionDigest, err := utils.DirDigest(filepath.Join(targetDir, version), appRuntimeSkip(appId, version))
+		if err != nil || localVersionDigest.Digest != indexVersion.Digest {
+			item.ChangedVersions = append(item.ChangedVersions, version)
+		}
+	}
+	for _, version := range localVersions {
+		if _, ok := indexApp.Versions[version]; !ok {
+			item.RemovedVersions = append(item.RemovedVersions, version)
+		}
+	}
+	sortVersionsDesc(item.AddedVersions)
+	sortVersionsDesc(item.RemovedVersions)
+	sortVersionsDesc(item.ChangedVersions)
+
+	// 对比摘要（跟踪本地修改的摘要不包含已安装版本运行时写入的数据）
+	localDigest, trackedDigest := "", ""
+	if result, err := utils.DirDigest(targetDir, nil); err == nil {
+		localDigest = result.Digest
+	}
+	if result, err := appSourceDigest(appId); err == nil {
+		trackedDigest = result.Digest
+	}
+	switch {
+	case localDigest == indexApp.Digest:
+		item.Status = SourcesDiffUnchanged
+	case source == nil:
+		// 来源未知（例如手动复制的应用），无法区分本地修改和远程更新
+		item.Status = SourcesDiffModified
+	case source.Type != AppSourceRepo:
+		item.Status = SourcesDiffForeign
+	case source.RemoteDigest == indexApp.Digest && source.Digest == trackedDigest:
+		item.Status = SourcesDiffUnchanged
+	case source.Digest != trackedDigest:
+		item.Status = SourcesDiffModified
+	default:
+		item.Status = SourcesDiffUpdated
+	}
+
+	return item
+}
+
+// sortVersionsDesc 按版本号从新到旧排序
+func sortVersionsDesc(versions []string) {
+	sort.Slice(versions, func(i, j int) bool {
+		return utils.CompareVersions(versions[i], versions[j]) > 0
+	})
+}
+
+// syncSourceApp 下载并更新单个应用，返回失败原因（为空表示成功）
+// - 正在安装或卸载的应用不更新（已安装的版本可能变化）
+func syncSourceApp(appId string, indexApp *SourcesIndexApp, tempDir, packageDir string) string {
+	if status := GetAppConfig(appId).Status; status == "installing" || status == "uninstalling" {
+		return i18n.T("AppIsRunning")
+	}
+
+	// 使用已下载的完整资源包中的应用
+	targetDir := filepath.Join(global.WorkDir, "apps", appId)
+	if packageDir != "" {
+		return replaceSourceApp(appId, indexApp, filepath.Join(packageDir, appId), tempDir)
+	}
+
+	// 本地摘要用于条件请求
+	localDigest := ""
+	if utils.IsDirExists(targetDir) {
+		if result, err := utils.DirDigest(targetDir, nil); err == nil {
+			localDigest = result.Digest
+		}
+	}
+
+	// 下载应用压缩包
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/%s/download/%s/", SourcesServer, global.APIVersion, url.PathEscape(appId)), nil)
+	if err != nil {
+		return i18n.T("DownloadFailed")
+	}
+	if localDigest != "" {
+		req.Header.Set("If-None-Match", "\""+localDigest+"\"")
+	}
+	resp, err := SourcesClient.Do(req)
+	if err != nil {
+		return i18n.T("DownloadFailed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return ""
+	}
+	if resp.StatusCode != http.StatusOK {
+		return i18n.T("DownloadFailed")
+	}
+
+	// 保存并解压
+	tarFile := filepath.Join(tempDir, appId+".tar.gz")
+	file, err := os.Create(tarFile)
+	if err != nil {
+		return i18n.T("SaveFileFailed")
+	}
+	_, err = io.Copy(file, resp.Body)
+	file.Close()
+	if err != nil {
+		return i18n.T("ReadDownloadDataFailed")
+	}
+	sourceDir := filepath.Join(tempDir, appId)
+	if err := utils.UnTarGz(tarFile, sourceDir); err != nil {
+		return i18n.T("ExtractFileFailed")
+	}
+	os.Remove(tarFile)
+
+	return replaceSourceApp(appId, indexApp, sourceDir, tempDir)
+}
+
+// replaceSourceApp 校验应用源目录后替换apps目录中的应用，返回失败原因（为空表示成功）
+func replaceSourceApp(appId string, indexApp *SourcesIndexApp, sourceDir, tempDir string) string {
+	// 校验摘要
+	sourceDigest, err := utils.DirDigest(sourceDir, nil)
+	if err != nil || sourceDigest.Digest != indexApp.Digest {
+		return i18n.T("DigestMismatch")
+	}
+
+	// 检查配置文件
+	if reason := CheckSourceConfig(appId, sourceDir); reason != "" {
+		return reason
+	}
+
+	// 替换目录：先移走旧内容再移入新内容，上游删除的版本和文件不会残留
+	// 已安装版本的目录保持不变：容器挂载其中的路径（运行时数据），生成docker-compose.yml和执行钩子也依赖其中的模板
+	targetDir := filepath.Join(global.WorkDir, "apps", appId)
+	oldDir := filepath.Join(tempDir, appId+".old")
+	if err := replaceAppDir(targetDir, sourceDir, oldDir, appInstalledVersion(appId)); err != nil {
+		return i18n.T("CopyFileFailed", err.Error())
+	}
+	os.RemoveAll(oldDir)
+
+	return ""
+}
+
+// appInstalledVersion 应用已安装（或安装失败）的版本，未安装时返回空
+func appInstalledVersion(appId string) string {
+	appConfig := GetAppConfig(appId)
+	if appConfig.Status == "not_installed" || appConfig.InstallVersion == "" {
+		return ""
+	}
+	return appConfig.InstallVersion
+}
+
+// replaceAppDir 用 sourceDir 的内容替换 targetDir，旧内容移动到 oldDir，失败时恢复原内容
+// - keep 为需要保留的子目录（已安装的版本），存在时保持不变，不使用 sourceDir 中的同名目录
+func replaceAppDir(targetDir, sourceDir, oldDir, keep string) error {
+	if keep == "" || !utils.IsDirExists(filepath.Join(targetDir, keep)) {
+		if utils.IsDirExists(targetDir) {
+			if err := os.Rename(targetDir, oldDir); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(sourceDir, targetDir); err != nil {
+			if utils.IsDirExists(oldDir) {
+				_ = os.Rename(oldDir, targetDir)
+			}
+			return err
+		}
+		return nil
+	}
+
+	// 逐项替换，跳过保留的目录
+	if err := os.MkdirAll(oldDir, 0755); err != nil {
+		return err
+	}
+	var movedOut, movedIn []string
+	restore := func() {
+		for _, name := range movedIn {
+			_ = os.Rename(filepath.Join(targetDir, name), filepath.Join(sourceDir, name))
+		}
+		for _, name := range movedOut {
+			_ = os.Rename(filepath.Join(oldDir, name), filepath.Join(targetDir, name))
+		}
+	}
+	entries, err := os.ReadDir(targetDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == keep {
+			continue
+		}
+		if err := os.Rename(filepath.Join(targetDir, entry.Name()), filepath.Join(oldDir, entry.Name())); err != nil {
+			restore()
+			return err
+		}
+		movedOut = append(movedOut, entry.Name())
+	}
+	entries, err = os.ReadDir(sourceDir)
+	if err != nil {
+		restore()
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == keep {
+			continue
+		}
+		if err := os.Rename(filepath.Join(sourceDir, entry.Name()), filepath.Join(targetDir, entry.Name())); err != nil {
+			restore()
+			return err
+		}
+		movedIn = append(movedIn, entry.Name())
+	}
+	return nil
+}
+
+// ParseETag 去除ETag的引号和弱校验前缀
+func ParseETag(etag string) string {
+	etag = strings.TrimSpace(etag)
+	etag = strings.TrimPrefix(etag, "W/")
+	return strings.Trim(etag, "\"")
+}
+
+// MatchETag 判断 If-None-Match 请求头是否命中ETag
+func MatchETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	for _, item := range strings.Split(ifNoneMatch, ",") {
+		item = strings.TrimSpace(item)
+		if item == "*" || ParseETag(item) == ParseETag(etag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+package models
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"appstore/server/global"
+	"appstore/server/i18n"
+	"appstore/server/utils"
+)
+
+// sourcesPackageMutex 防止并发重复生成资源包
+var sourcesPackageMutex sync.Mutex
+
+// SourcesPackage 应用商店资源包
+type SourcesPackage struct {
+	Path      string    // 资源包文件路径
+	File      *os.File  // 已打开的资源包文件（持有锁时打开，清理缓存后仍可读取，使用后需关闭）
+	Digest    string    // apps目录内容摘要
+	UpdatedAt time.Time // apps目录最后修改时间
+}
+
+// sourcesPackageDir 资源包缓存目录
+func sourcesPackageDir() string {
+	return filepath.Join(global.WorkDir, "temp", "sources_package")
+}
+
+// BuildSourcesPackage 获取应用商店资源包
+// 1、加锁后计算apps目录内容摘要，以摘要作为缓存文件名
+// 2、缓存存在则直接返回
+// 3、写入临时文件，同时计算打包内容的摘要（打包期间apps目录变化时摘要与打包内容保持一致），完成后以该摘要重命名，避免并发请求读到不完整的文件
+// 4、清理旧的缓存文件
+// 5、持有锁时打开资源包文件，避免返回后被并发的清理删除
+// 6、返回资源包（第一个参数不为空表示成功，调用方需关闭 File）
+func BuildSourcesPackage() (*SourcesPackage, string, error) {
+	appsDir := filepath.Join(global.WorkDir, "apps")
+	if !utils.IsDirExists(appsDir) {
+		return nil, i18n.T("AppsDirNotFound"), nil
+	}
+
+	sourcesPackageMutex.Lock()
+	defer sourcesPackageMutex.Unlock()
+
+	// 只打包应用目录，跳过apps根目录下的文件
+	digest, err := utils.DirDigest(appsDir, func(relPath string, info os.FileInfo) bool {
+		return !info.IsDir() && !strings.Contains(relPath, string(filepath.Separator))
+	})
+	if err != nil {
+		return nil, i18n.T("GetAppListFailed"), err
+	}
+
+	// 缓存存在
+	packageDir := sourcesPackageDir()
+	if path := sourcesPackagePath(digest.Digest); utils.IsFileExists(path) {
+		return openSourcesPackage(&SourcesPackage{
+			Path:      path,
+			Digest:    digest.Digest,
+			UpdatedAt: digest.UpdatedAt,
+		})
+	}
+
+	// 获取所有子目录
+	appIds, err := utils.GetSubDirs(appsDir)
+	if err != nil {
+		return nil, i18n.T("GetAppListFailed"), err
+	}
+
+	// 写入临时文件
+	if err := os.MkdirAll(packageDir, 0755); err != nil {
+		return nil, i18n.T("CreateTempDirFailed"), err
+	}
+	tempFile, err := os.CreateTemp(packageDir, ".building-*")
+	if err != nil {
+		return nil, i18n.T("CreateZipFileFailed"), err
+	}
+	packed, err := writeSourcesPackage(tempFile, appsDir, appIds)
+	if err != nil {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+		return nil, i18n.T("PackageFileFailed"), err
+	}
+	if err := tempFile.Close(); err != nil {
+		os.Remove(tempFile.Name())
+		return nil, i18n.T("PackageFileFailed"), err
+	}
+	if err := os.Chmod(tempFile.Name(), 0644); err != nil {
+		os.Remove(tempFile.Name())
+		return nil, i18n.T("PackageFileFailed"), err
+	}
+	pkg := &SourcesPackage{
+		Path:      sourcesPackagePath(packed.Digest),
+		Digest:    packed.Digest,
+		UpdatedAt: packed.UpdatedAt,
+	}
+	if err := os.Rename(tempFile.Name(), pkg.Path); err != nil {
+		os.Remove(tempFile.Name())
+		return nil, i18n.T("PackageFileFailed"), err
+	}
+
+	// 清理旧的缓存文件（持有锁，不会有其他正在生成的文件，保留仓库索引缓存）
+	if entries, err := os.ReadDir(packageDir); err == nil {
+		for _, entry := range entries {
+			if entry.Name() != filepath.Base(pkg.Path) && entry.Name() != filepath.Base(sourcesIndexCacheFile()) {
+				os.RemoveAll(filepath.Join(packageDir, entry.Name()))
+			}
+		}
+	}
+
+	return openSourcesPackage(pkg)
+}
+
+// sourcesPackagePath 摘要对应的资源包缓存文件路径
+func sourcesPackagePath(digest string) string {
+	return filepath.Join(sourcesPackageDir(), strings.TrimPrefix(digest, utils.DigestPrefix)+".tar.gz")
+}
+
+// openSourcesPackage 打开资源包文件（需持有 sourcesPackageMutex）
+func openSourcesPackage(pkg *SourcesPackage) (*SourcesPackage, string, error) {
+	file, err := os.Open(pkg.Path)
+	if err != nil {
+		return nil, i18n.T("PackageFileFailed"), err
+	}
+	pkg.File = file
+	return pkg, "", nil
+}
+
+// sourcesIndexCacheFile 仓库索引缓存文件（与资源包缓存在同一目录，一起清除）
+func sourcesIndexCacheFile() string {
+	return filepath.Join(sourcesPackageDir(), "index.json")
+}
+
+// BuildSourcesIndex 获取apps目录的仓库索引，缓存到应用包变化（InvalidateSourcesPackage）为止
+func BuildSourcesIndex() (*SourcesIndex, error) {
+	sourcesPackageMutex.Lock()
+	defer sourcesPackageMutex.Unlock()
+
+	// 缓存存在
+	if data, err := os.ReadFile(sourcesIndexCacheFile()); err == nil {
+		index := &SourcesIndex{}
+		if json.Unmarshal(data, index) == nil && index.Apps != nil {
+			return index, nil
+		}
+	}
+
+	index, err := buildSourcesIndex(filepath.Join(global.WorkDir, "apps"))
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(index); err == nil && os.MkdirAll(sourcesPackageDir(), 0755) == nil {
+		_ = os.WriteFile(sourcesIndexCacheFile(), data, 0644)
+	}
+	return index, nil
+}
+
+// InvalidateSourcesPackage 应用包变化时清除资源包和仓库索引缓存
+func InvalidateSourcesPackage() {
+	sourcesPackageMutex.Lock()
+	defer sourcesPackageMutex.Unlock()
+
+	os.RemoveAll(sourcesPackageDir())
+}
+
+// writeSourcesPackage 将应用目录写入tar.gz，返回打包内容的摘要（只计算普通文件，与 utils.DirDigest 相同）
+func writeSourcesPackage(w io.Writer, appsDir string, appIds []string) (*utils.DirDigestResult, error) {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	result := &utils.DirDigestResult{}
+	files := make(map[string]string)
+
+	// 遍历每个应用目录
+	for _, appId := range appIds {
+		appDir := filepath.Join(appsDir, appId)
+		err := filepath.Walk(appDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			// 获取相对路径
+			relPath, err := filepath.Rel(appsDir, path)
+			if err != nil {
+				return err
+			}
+
+			// 创建tar文件头
+			header, err := tar.FileInfoHeader(info, info.Name())
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(relPath)
+
+			// 写入文件头
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+
+			// 如果是目录，跳过写入内容
+			if info.IsDir() {
+				return nil
+			}
+
+			// 如果是文件，写入文件内容，同时计算摘要
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			h := sha256.New()
+			if _, err := io.Copy(io.MultiWriter(tw, h), file); err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				files[header.Name] = hex.EncodeToString(h.Sum(nil))
+				result.Size += info.Size()
+				if info.ModTime().After(result.UpdatedAt) {
+					result.UpdatedAt = info.ModTime()
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	result.Digest = utils.FilesDigest(files)
+	return result, nil
+}
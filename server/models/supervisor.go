@@ -0,0 +1,483 @@
+package models
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"appstore/server/global"
+	"appstore/server/utils"
+)
+
+// 容器状态
+const (
+	ContainerRunning    = "running"
+	ContainerExited     = "exited"
+	ContainerUnhealthy  = "unhealthy"
+	ContainerRestarting = "restarting"
+)
+
+const (
+	supervisorResyncInterval = 5 * time.Minute  // 定期全量同步容器状态（补充可能丢失的事件）
+	supervisorRetryInterval  = 5 * time.Second  // 事件流中断后重新订阅的间隔
+	supervisorBackoffBase    = 10 * time.Second // 第一次重启前的等待时间，之后每次翻倍
+	supervisorBackoffMax     = 5 * time.Minute  // 重启前的最长等待时间
+	crashLoopWindow          = 10 * time.Minute // 统计容器故障的时间窗口
+	crashLoopThreshold       = 5                // 时间窗口内故障次数达到该值时判定为反复崩溃
+)
+
+// ContainerState 守护跟踪的容器状态
+type ContainerState struct {
+	Name      string    `json:"name"`
+	Service   string    `json:"service"`
+	State     string    `json:"state"` // running、exited、unhealthy、restarting
+	ExitCode  int       `json:"exit_code"`
+	UpdatedAt time.Time `json:"updated_at"`
+	stopping  bool      // 容器正在被停止（kill、stop 事件，例如 compose 重建、重启或手动停止），随后的退出不计为故障
+}
+
+// supervisedApp 守护中的应用
+type supervisedApp struct {
+	containers map[string]*ContainerState // 按容器名称
+	failures   []time.Time                // 时间窗口内的故障（异常退出、不健康）
+	restarts   int                        // 连续重启次数，用于计算退避时间
+	timer      *time.Timer                // 等待中的重启
+	restarting bool                       // 正在重启
+}
+
+// containerSupervisor 容器守护
+type containerSupervisor struct {
+	ctx  context.Context
+	mu   sync.Mutex
+	apps map[string]*supervisedApp
+}
+
+// activeSupervisor 运行中的容器守护，安装、卸载开始时重置应用的守护状态
+var activeSupervisor atomic.Pointer[containerSupervisor]
+
+// dockerEvent docker events 输出的事件
+type dockerEvent struct {
+	Action string `json:"Action"`
+	Actor  struct {
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+}
+
+// StartContainerSupervisor 启动容器守护，ctx 取消时停止
+// - 订阅Docker容器事件，跟踪已安装应用的容器状态
+// - 容器异常退出、不健康或全部不存在时按指数退避重启（10秒起，最长5分钟）
+// - 10分钟内故障5次判定为反复崩溃，标记应用为降级（degraded）并停止自动重启，重新安装或容器恢复正常后清除
+// - 事件流中断时重新订阅，并定期全量同步容器状态
+func StartContainerSupervisor(ctx context.Context) {
+	s := &containerSupervisor{ctx: ctx, apps: map[string]*supervisedApp{}}
+	activeSupervisor.Store(s)
+	defer activeSupervisor.CompareAndSwap(s, nil)
+	defer s.stop()
+
+	go func() {
+		ticker := time.NewTicker(supervisorResyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.syncAll()
+			}
+		}
+	}()
+
+	for {
+		if err := s.watchEvents(); err != nil && ctx.Err() == nil {
+			fmt.Printf("[Supervisor] Docker events stream stopped: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(supervisorRetryInterval):
+		}
+	}
+}
+
+// watchEvents 订阅Docker容器事件，订阅后先全量同步一次，事件流结束时返回
+func (s *containerSupervisor) watchEvents() error {
+	cmd := exec.CommandContext(s.ctx, "docker", "events", "--format", "{{json .}}",
+		"--filter", "type=container", "--filter", "label=com.docker.compose.project")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	s.syncAll()
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		event := dockerEvent{}
+		if json.Unmarshal(scanner.Bytes(), &event) == nil {
+			s.handleEvent(event)
+		}
+	}
+	return cmd.Wait()
+}
+
+// stop 取消等待中的重启
+func (s *containerSupervisor) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, app := range s.apps {
+		if app.timer != nil {
+			app.timer.Stop()
+			app.timer = nil
+		}
+	}
+}
+
+// resetSupervisedApp 清除应用的守护状态（故障记录、退避次数、等待中的重启），安装、卸载开始时调用
+func resetSupervisedApp(appId string) {
+	s := activeSupervisor.Load()
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if app, ok := s.apps[appId]; ok {
+		if app.timer != nil {
+			app.timer.Stop()
+		}
+		delete(s.apps, appId)
+	}
+}
+
+// app 获取守护中的应用，不存在时创建（需持有锁）
+func (s *containerSupervisor) app(appId string) *supervisedApp {
+	app, ok := s.apps[appId]
+	if !ok {
+		app = &supervisedApp{containers: map[string]*ContainerState{}}
+		s.apps[appId] = app
+	}
+	return app
+}
+
+// handleEvent 处理容器事件
+// - 只跟踪守护中的应用，安装、升级、卸载过程中容器的停止和重建不计为故障
+// - kill、stop 之后的退出（compose 重建或重启、手动停止）不计为故障
+// - 退出后由 Docker 按重启策略重启的容器标记为 restarting，restart 事件后恢复为 running
+func (s *containerSupervisor) handleEvent(event dockerEvent) {
+	attributes := event.Actor.Attributes
+	appId := appIdForProject(attributes["com.docker.compose.project"])
+	name := attributes["name"]
+	if appId == "" || name == "" {
+		return
+	}
+	invalidateAppRuntime(appId)
+	if !supervised(appId) {
+		return
+	}
+
+	// 退出后是否由 Docker 按重启策略重启
+	restarting := false
+	if event.Action == "die" {
+		restarting = containerRestarting(s.ctx, name)
+	}
+
+	s.mu.Lock()
+	app := s.app(appId)
+	container, ok := app.containers[name]
+	if !ok {
+		container = &ContainerState{Name: name, Service: attributes["com.docker.compose.service"]}
+		app.containers[name] = container
+	}
+	now := time.Now()
+	switch {
+	case event.Action == "start" || event.Action == "restart" || event.Action == "unpause":
+		container.State, container.ExitCode, container.stopping = ContainerRunning, 0, false
+	case event.Action == "kill" || event.Action == "stop":
+		container.stopping = true
+		if event.Action == "stop" {
+			container.State = ContainerExited
+		}
+	case event.Action == "health_status: healthy":
+		container.State = ContainerRunning
+	case strings.HasPrefix(event.Action, "health_status") && strings.Contains(event.Action, "unhealthy"):
+		container.State = ContainerUnhealthy
+		if !app.restarting {
+			app.failures = append(app.failures, now)
+		}
+	case event.Action == "die":
+		container.State = ContainerExited
+		if restarting {
+			container.State = ContainerRestarting
+		}
+		fmt.Sscanf(attributes["exitCode"], "%d", &container.ExitCode)
+		if container.ExitCode != 0 && !container.stopping && !app.restarting {
+			app.failures = append(app.failures, now)
+		}
+	case event.Action == "destroy":
+		delete(app.containers, name)
+	default:
+		s.mu.Unlock()
+		return
+	}
+	container.UpdatedAt = now
+	s.mu.Unlock()
+
+	s.evaluate(appId)
+}
+
+// containerRestarting 容器是否正在由 Docker 按重启策略重启
+func containerRestarting(ctx context.Context, name string) bool {
+	output, err := exec.CommandContext(ctx, "docker", "inspect", "--format", "{{.State.Restarting}}", name).Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+// syncAll 全量同步所有已安装应用的容器状态
+func (s *containerSupervisor) syncAll() {
+	entries, err := os.ReadDir(filepath.Join(global.WorkDir, "config"))
+	if err != nil {
+		fmt.Printf("[Supervisor] Failed to read directory config: %v\n", err)
+		return
+	}
+	appIds := map[string]bool{}
+	for _, entry := range entries {
+		if entry.IsDir() && supervised(entry.Name()) {
+			appIds[entry.Name()] = true
+			s.syncApp(entry.Name())
+		}
+	}
+
+	// 不再守护的应用
+	s.mu.Lock()
+	for appId, app := range s.apps {
+		if !appIds[appId] && !app.restarting {
+			if app.timer != nil {
+				app.timer.Stop()
+			}
+			delete(s.apps, appId)
+		}
+	}
+	s.mu.Unlock()
+}
+
+// syncApp 同步应用的容器状态并检查是否需要重启
+func (s *containerSupervisor) syncApp(appId string) {
+	containers, err := composeContainers(s.ctx, appId)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	app := s.app(appId)
+	app.containers = map[string]*ContainerState{}
+	for _, container := range containers {
+		state := container.State
+		if state == ContainerRunning && container.Health == "unhealthy" {
+			state = ContainerUnhealthy
+		}
+		app.containers[container.Name] = &ContainerState{
+			Name:      container.Name,
+			Service:   container.Service,
+			State:     state,
+			ExitCode:  container.ExitCode,
+			UpdatedAt: time.Now(),
+		}
+	}
+	s.mu.Unlock()
+
+	s.evaluate(appId)
+}
+
+// evaluate 检查应用的容器状态：需要时安排重启，反复崩溃时标记为降级
+func (s *containerSupervisor) evaluate(appId string) {
+	if !supervised(appId) {
+		return
+	}
+	appConfig := GetAppConfig(appId)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app := s.app(appId)
+	if app.restarting {
+		return
+	}
+
+	// 只保留时间窗口内的故障
+	now := time.Now()
+	failures := app.failures[:0]
+	for _, failure := range app.failures {
+		if now.Sub(failure) < crashLoopWindow {
+			failures = append(failures, failure)
+		}
+	}
+	app.failures = failures
+	failed, missing := app.failedServices()
+
+	// 已降级的应用不自动重启，容器恢复正常且没有新的故障后清除
+	if appConfig.Degraded != "" {
+		if len(failed) == 0 && !missing && len(app.failures) == 0 {
+			appConfig.Degraded = ""
+			if err := SaveAppConfig(appId, appConfig); err == nil {
+				AppLogInfo(appId, "[Supervisor] containers recovered, degraded cleared")
+			}
+			app.restarts = 0
+		}
+		return
+	}
+
+	// 反复崩溃
+	if len(app.failures) >= crashLoopThreshold {
+		if app.timer != nil {
+			app.timer.Stop()
+			app.timer = nil
+		}
+		appConfig.Degraded = fmt.Sprintf("crash loop: %d failures in %s (%s)", len(app.failures), crashLoopWindow, strings.Join(failed, ", "))
+		if err := SaveAppConfig(appId, appConfig); err != nil {
+			AppLogError(appId, "Failed to save degraded status: "+err.Error())
+		}
+		AppLogError(appId, "[Supervisor] "+appConfig.Degraded+", automatic restart stopped")
+		return
+	}
+
+	if len(failed) == 0 && !missing {
+		if len(app.failures) == 0 {
+			app.restarts = 0
+		}
+		return
+	}
+	if app.timer != nil {
+		return
+	}
+
+	// 按指数退避安排重启
+	delay := supervisorBackoffBase << app.restarts
+	if delay > supervisorBackoffMax || delay <= 0 {
+		delay = supervisorBackoffMax
+	}
+	app.restarts++
+	app.timer = time.AfterFunc(delay, func() { s.restart(appId) })
+	AppLogWarn(appId, fmt.Sprintf("[Supervisor] restart scheduled in %s: %s", delay, strings.Join(append(failed, missingLabel(missing)...), ", ")))
+}
+
+// failedServices 需要重启的服务（异常退出、不健康）以及容器是否全部不存在（需持有锁）
+func (a *supervisedApp) failedServices() ([]string, bool) {
+	failed := []string{}
+	for _, container := range a.containers {
+		if (container.State == ContainerExited && container.ExitCode != 0) || container.State == ContainerUnhealthy {
+			if !slices.Contains(failed, container.Service) {
+				failed = append(failed, container.Service)
+			}
+		}
+	}
+	sort.Strings(failed)
+	return failed, len(a.containers) == 0
+}
+
+// restart 重启应用中异常的服务，容器全部不存在时重新创建（期间显示维护页面）
+func (s *containerSupervisor) restart(appId string) {
+	s.mu.Lock()
+	app := s.app(appId)
+	app.timer = nil
+	if s.ctx.Err() != nil || !supervised(appId) || GetAppConfig(appId).Degraded != "" {
+		s.mu.Unlock()
+		return
+	}
+	failed, missing := app.failedServices()
+	unhealthy, exited := []string{}, []string{}
+	for _, container := range app.containers {
+		if container.State == ContainerUnhealthy && !slices.Contains(unhealthy, container.Service) {
+			unhealthy = append(unhealthy, container.Service)
+		} else if container.State == ContainerExited && container.ExitCode != 0 && !slices.Contains(exited, container.Service) {
+			exited = append(exited, container.Service)
+		}
+	}
+	if len(failed) == 0 && !missing {
+		s.mu.Unlock()
+		return
+	}
+	app.restarting = true
+	s.mu.Unlock()
+
+	if missing {
+		AppLogInfo(appId, "[Supervisor] up starting...")
+		maintenance := startMaintenance(appId)
+		err := runComposeCommand(s.ctx, appId, "compose", "up", "-d", "--remove-orphans")
+		if err != nil {
+			AppLogError(appId, "[Supervisor] up failed: "+err.Error())
+		} else if maintenance {
+			if err := waitAppHealthy(s.ctx, appId); err != nil {
+				AppLogWarn(appId, "[Supervisor] "+err.Error())
+			}
+		}
+		if maintenance {
+			stopMaintenance(appId)
+			if out, err := ReloadNginx(appId, 3); err != nil {
+				AppLogError(appId, "[Supervisor] nginx reload failed: "+out+" "+err.Error())
+			}
+		}
+	} else {
+		if len(exited) > 0 {
+			AppLogInfo(appId, "[Supervisor] starting "+strings.Join(exited, ", "))
+			args := append([]string{"compose", "up", "-d", "--no-deps"}, exited...)
+			if err := runComposeCommand(s.ctx, appId, args...); err != nil {
+				AppLogError(appId, "[Supervisor] start failed: "+err.Error())
+			}
+		}
+		if len(unhealthy) > 0 {
+			AppLogInfo(appId, "[Supervisor] restarting "+strings.Join(unhealthy, ", "))
+			args := append([]string{"compose", "restart"}, unhealthy...)
+			if err := runComposeCommand(s.ctx, appId, args...); err != nil {
+				AppLogError(appId, "[Supervisor] restart failed: "+err.Error())
+			}
+		}
+	}
+
+	s.mu.Lock()
+	app.restarting = false
+	s.mu.Unlock()
+
+	// 重新同步状态，仍然异常时继续按退避时间重启
+	if s.ctx.Err() == nil {
+		s.syncApp(appId)
+	}
+}
+
+// supervised 应用是否需要守护：已安装且存在 docker-compose.yml（安装、卸载过程中由安装流程管理）
+func supervised(appId string) bool {
+	if !utils.IsFileExists(filepath.Join(global.WorkDir, "config", appId, "docker-compose.yml")) {
+		return false
+	}
+	return GetAppConfig(appId).Status == "installed"
+}
+
+// appIdForProject 根据compose项目名称获取应用ID，只匹配应用当前使用的项目（蓝绿升级中的另一个项目返回空）
+func appIdForProject(project string) string {
+	appId := strings.TrimPrefix(project, "dootask-app-")
+	if appId == project || appId == "" {
+		return ""
+	}
+	for _, candidate := range []string{appId, strings.TrimSuffix(appId, composeProjectSuffix)} {
+		if utils.IsDirExists(filepath.Join(global.WorkDir, "config", candidate)) && composeProject(candidate, GetAppConfig(candidate)) == project {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// missingLabel 容器全部不存在时的日志说明
+func missingLabel(missing bool) []string {
+	if missing {
+		return []string{"no containers"}
+	}
+	return nil
+}
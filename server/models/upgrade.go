@@ -0,0 +1,464 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"appstore/server/global"
+	"appstore/server/i18n"
+	"appstore/server/utils"
+)
+
+// 自动升级策略
+const (
+	UpgradePolicyManual    = "manual"     // 手动升级（默认）
+	UpgradePolicyNotify    = "notify"     // 有新版本时通知
+	UpgradePolicyAutoPatch = "auto-patch" // 自动升级修订版本（1.2.x）
+	UpgradePolicyAutoMinor = "auto-minor" // 自动升级次版本（1.x）
+	UpgradePolicyAutoAll   = "auto-all"   // 自动升级到最新版本
+)
+
+// UpgradePolicies 支持的自动升级策略
+var UpgradePolicies = []string{
+	UpgradePolicyManual,
+	UpgradePolicyNotify,
+	UpgradePolicyAutoPatch,
+	UpgradePolicyAutoMinor,
+	UpgradePolicyAutoAll,
+}
+
+// 发布通道（订阅的通道包含更稳定的通道）
+const (
+	VersionChannelStable = "stable" // 正式版本
+	VersionChannelBeta   = "beta"   // 测试版本（beta、rc 等预发布版本）
+	VersionChannelDev    = "dev"    // 开发版本（其他预发布版本）
+)
+
+// VersionChannels 发布通道，按稳定程度排序
+var VersionChannels = []string{
+	VersionChannelStable,
+	VersionChannelBeta,
+	VersionChannelDev,
+}
+
+// 自动升级决策
+const (
+	UpgradeActionNotify  = "notify"  // 只通知
+	UpgradeActionQueued  = "queued"  // 已加入升级队列
+	UpgradeActionWaiting = "waiting" // 等待进入维护时间段
+	UpgradeActionBlocked = "blocked" // 需要先卸载，不能自动升级
+)
+
+// UpgradeDecision 自动升级策略的评估结果
+type UpgradeDecision struct {
+	ID               string `json:"id"`
+	Policy           string `json:"policy"`
+	InstalledVersion string `json:"installed_version"`
+	LatestVersion    string `json:"latest_version"`
+	TargetVersion    string `json:"target_version,omitempty"` // 按策略可以自动升级到的版本
+	Action           string `json:"action"`                   // notify, queued, waiting, blocked
+	Reason           string `json:"reason,omitempty"`
+}
+
+// AppInternalUpgradePolicyRequest 设置自动升级策略的请求结构
+type AppInternalUpgradePolicyRequest struct {
+	AppID  string `json:"appid" validate:"required"`
+	Policy string `json:"policy" validate:"required,oneof=manual notify auto-patch auto-minor auto-all"`
+	Window string `json:"window" validate:"omitempty"`
+}
+
+// AppInternalChannelRequest 设置发布通道的请求结构
+type AppInternalChannelRequest struct {
+	AppID   string `json:"appid" validate:"required"`
+	Channel string `json:"channel" validate:"required,oneof=stable beta dev"`
+}
+
+// AppInternalPinRequest 固定应用版本的请求结构
+type AppInternalPinRequest struct {
+	AppID        string `json:"appid" validate:"required"`
+	Pinned       bool   `json:"pinned"`
+	VersionRange string `json:"version_range" validate:"omitempty"` // 允许升级的版本范围，例如 ~1.2
+}
+
+var (
+	autoUpgradeMutex   sync.Mutex
+	autoUpgradeQueued  = make(map[string]bool) // 已加入升级队列的应用
+	autoUpgradePending = make(map[string]bool) // 等待维护时间段的应用
+	autoUpgradeQueue   = make(chan string, 100)
+)
+
+// VersionChannel 获取版本所属的发布通道
+// - 没有预发布标识的版本属于 stable
+// - 预发布标识以 beta、rc、pre、preview 开头的版本属于 beta，例如 1.2.3-beta.1、1.2.3-rc.1
+// - 其他预发布版本属于 dev，例如 1.2.3-alpha、1.2.3-dev.20240101、1.2.3-nightly
+func VersionChannel(version string) string {
+	prerelease := strings.ToLower(utils.VersionPrerelease(version))
+	if prerelease == "" {
+		return VersionChannelStable
+	}
+	if regexp.MustCompile(`^(beta|rc|pre|preview)\d*([.-]|$)`).MatchString(prerelease) {
+		return VersionChannelBeta
+	}
+	return VersionChannelDev
+}
+
+// findUpgradeTarget 按策略查找可以自动升级到的最高版本，没有则返回空
+func findUpgradeTarget(policy, installedVersion string, versions []string) string {
+	installed := utils.VersionSegments(installedVersion)
+	for _, version := range versions {
+		if utils.CompareVersions(version, installedVersion) <= 0 {
+			continue
+		}
+		segments := utils.VersionSegments(version)
+		switch policy {
+		case UpgradePolicyAutoPatch:
+			if segments[0] == installed[0] && segments[1] == installed[1] {
+				return version
+			}
+		case UpgradePolicyAutoMinor:
+			if segments[0] == installed[0] {
+				return version
+			}
+		case UpgradePolicyAutoAll:
+			return version
+		}
+	}
+	return ""
+}
+
+// evaluateAppUpgrade 评估单个应用的自动升级策略，不需要处理时返回nil
+func evaluateAppUpgrade(app *App, now time.Time) *UpgradeDecision {
+	if app.Config == nil || app.Config.Status != "installed" || app.Config.InstallVersion == "" || len(app.Versions) == 0 {
+		return nil
+	}
+	policy := app.Config.UpgradePolicy
+	if policy == "" || policy == UpgradePolicyManual || app.Config.Pinned {
+		return nil
+	}
+	versions := app.CompatibleVersions(app.Config.AllowedVersions(app.Versions))
+	if len(versions) == 0 || utils.CompareVersions(versions[0], app.Config.InstallVersion) <= 0 {
+		return nil
+	}
+
+	decision := &UpgradeDecision{
+		ID:               app.ID,
+		Policy:           policy,
+		InstalledVersion: app.Config.InstallVersion,
+		LatestVersion:    versions[0],
+		TargetVersion:    findUpgradeTarget(policy, app.Config.InstallVersion, versions),
+		Action:           UpgradeActionNotify,
+	}
+	if decision.TargetVersion == "" {
+		return decision
+	}
+
+	if require := findRequireUninstall(app); require != nil {
+		decision.Action = UpgradeActionBlocked
+		decision.Reason, _ = require.Reason.(string)
+		return decision
+	}
+	if !InUpdateWindow(app.Config.UpgradeWindow, now) {
+		decision.Action = UpgradeActionWaiting
+		return decision
+	}
+	decision.Action = UpgradeActionQueued
+	return decision
+}
+
+// EvaluateUpgradePolicies 评估所有已安装应用的自动升级策略（更新应用列表后执行）
+// - notify 及不在策略范围内的新版本只记录通知
+// - 固定版本的应用不处理，设置了版本范围的应用只考虑范围内的版本
+// - 需要先卸载的应用不会自动升级
+// - 不在维护时间段内的应用等待时间段开始后再升级
+func EvaluateUpgradePolicies() []UpgradeDecision {
+	decisions := []UpgradeDecision{}
+	now := time.Now()
+	for _, app := range NewApps(nil) {
+		decision := evaluateAppUpgrade(app, now)
+		if decision == nil {
+			continue
+		}
+		switch decision.Action {
+		case UpgradeActionNotify:
+			AppLogInfo(app.ID, fmt.Sprintf("[AutoUpgrade] new version %s available (installed %s)", decision.LatestVersion, decision.InstalledVersion))
+		case UpgradeActionBlocked:
+			AppLogWarn(app.ID, fmt.Sprintf("[AutoUpgrade] upgrade to %s blocked, uninstall required: %s", decision.TargetVersion, decision.Reason))
+		case UpgradeActionWaiting:
+			autoUpgradeMutex.Lock()
+			autoUpgradePending[app.ID] = true
+			autoUpgradeMutex.Unlock()
+		case UpgradeActionQueued:
+			enqueueAutoUpgrade(app.ID)
+		}
+		decisions = append(decisions, *decision)
+	}
+	return decisions
+}
+
+// enqueueAutoUpgrade 加入升级队列（已在队列中则忽略）
+func enqueueAutoUpgrade(appId string) {
+	autoUpgradeMutex.Lock()
+	defer autoUpgradeMutex.Unlock()
+
+	delete(autoUpgradePending, appId)
+	if autoUpgradeQueued[appId] {
+		return
+	}
+	select {
+	case autoUpgradeQueue <- appId:
+		autoUpgradeQueued[appId] = true
+	default:
+		AppLogWarn(appId, "[AutoUpgrade] upgrade queue is full, skipped")
+	}
+}
+
+// runAutoUpgrade 执行自动升级，并等待安装完成
+func runAutoUpgrade(appId string) {
+	defer func() {
+		autoUpgradeMutex.Lock()
+		delete(autoUpgradeQueued, appId)
+		autoUpgradeMutex.Unlock()
+	}()
+
+	// 重新评估（排队期间状态可能已变化）
+	app, err := NewApp(appId)
+	if err != nil {
+		return
+	}
+	decision := evaluateAppUpgrade(app, time.Now())
+	if decision == nil || decision.Action != UpgradeActionQueued {
+		return
+	}
+
+	AppLogInfo(appId, fmt.Sprintf("[AutoUpgrade] upgrade from %s to %s starting...", decision.InstalledVersion, decision.TargetVersion))
+	version, stderr, err := InstallApp(&AppInternalInstallRequest{
+		AppID:     appId,
+		Version:   decision.TargetVersion,
+		Params:    app.Config.Params,
+		Resources: app.Config.Resources,
+	})
+	if version == "" {
+		if err != nil {
+			stderr = fmt.Sprintf("%s: %v", stderr, err)
+		}
+		AppLogError(appId, "[AutoUpgrade] upgrade failed: "+stderr)
+		return
+	}
+
+	// 等待安装完成，避免同时升级多个应用
+	if status := WaitAppFinished(appId, 30*time.Minute); status != "installing" {
+		AppLogInfo(appId, fmt.Sprintf("[AutoUpgrade] upgrade to %s finished: %s", version, status))
+		return
+	}
+	AppLogWarn(appId, fmt.Sprintf("[AutoUpgrade] upgrade to %s still running after 30 minutes", version))
+}
+
+// StartAutoUpgradeDaemon 启动自动升级守护
+// - 依次执行升级队列中的应用
+// - 每分钟检查一次等待维护时间段的应用
+func StartAutoUpgradeDaemon() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case appId := <-autoUpgradeQueue:
+			runAutoUpgrade(appId)
+		case <-ticker.C:
+			autoUpgradeMutex.Lock()
+			appIds := make([]string, 0, len(autoUpgradePending))
+			for appId := range autoUpgradePending {
+				appIds = append(appIds, appId)
+			}
+			autoUpgradeMutex.Unlock()
+
+			now := time.Now()
+			for _, appId := range appIds {
+				app, err := NewApp(appId)
+				var decision *UpgradeDecision
+				if err == nil {
+					decision = evaluateAppUpgrade(app, now)
+				}
+				switch {
+				case decision != nil && decision.Action == UpgradeActionQueued:
+					enqueueAutoUpgrade(appId)
+				case decision != nil && decision.Action == UpgradeActionWaiting:
+					continue
+				default:
+					autoUpgradeMutex.Lock()
+					delete(autoUpgradePending, appId)
+					autoUpgradeMutex.Unlock()
+				}
+			}
+		}
+	}
+}
+
+// SetUpgradePolicy 设置已安装应用的自动升级策略
+// 返回更新后的应用配置（第一个参数不为空表示成功）
+func SetUpgradePolicy(req *AppInternalUpgradePolicyRequest) (*AppConfig, string, error) {
+	if !slices.Contains(UpgradePolicies, req.Policy) {
+		return nil, i18n.T("InvalidUpgradePolicy", req.Policy), nil
+	}
+	if _, _, err := ParseUpdateWindow(req.Window); err != nil {
+		return nil, i18n.T("InvalidUpgradeWindow", req.Window), err
+	}
+
+	appConfig := GetAppConfig(req.AppID)
+	if appConfig.Status == "not_installed" {
+		return nil, i18n.T("AppNotInstalledError"), nil
+	}
+	appConfig.UpgradePolicy = req.Policy
+	appConfig.UpgradeWindow = req.Window
+	if err := SaveAppConfig(req.AppID, appConfig); err != nil {
+		return nil, i18n.T("SaveConfigFailed"), err
+	}
+	return appConfig, "", nil
+}
+
+// SetAppPin 设置已安装应用的固定版本和允许升级的版本范围
+// 返回更新后的应用配置（第一个参数不为空表示成功）
+func SetAppPin(req *AppInternalPinRequest) (*AppConfig, string, error) {
+	if req.VersionRange != "" {
+		if err := utils.ValidateVersionConstraint(req.VersionRange); err != nil {
+			return nil, i18n.T("InvalidVersionRange", req.VersionRange), err
+		}
+	}
+
+	appConfig := GetAppConfig(req.AppID)
+	if appConfig.Status == "not_installed" {
+		return nil, i18n.T("AppNotInstalledError"), nil
+	}
+	appConfig.Pinned = req.Pinned
+	appConfig.VersionRange = req.VersionRange
+	if err := SaveAppConfig(req.AppID, appConfig); err != nil {
+		return nil, i18n.T("SaveConfigFailed"), err
+	}
+
+	// 固定版本后不再等待自动升级
+	if appConfig.Pinned {
+		autoUpgradeMutex.Lock()
+		delete(autoUpgradePending, req.AppID)
+		autoUpgradeMutex.Unlock()
+	}
+	return appConfig, "", nil
+}
+
+// SetAppChannel 设置应用订阅的发布通道（未安装的应用也可以设置，安装时使用）
+// 返回更新后的应用配置（第一个参数不为空表示成功）
+func SetAppChannel(req *AppInternalChannelRequest) (*AppConfig, string, error) {
+	if !slices.Contains(VersionChannels, req.Channel) {
+		return nil, i18n.T("InvalidVersionChannel", req.Channel), nil
+	}
+	if _, err := NewApp(req.AppID); err != nil {
+		return nil, i18n.T("GetAppDetailFailed"), err
+	}
+
+	configDir := filepath.Join(global.WorkDir, "config", req.AppID)
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return nil, i18n.T("CreateConfigDirFailed"), err
+	}
+
+	appConfig := GetAppConfig(req.AppID)
+	appConfig.Channel = req.Channel
+	if err := SaveAppConfig(req.AppID, appConfig); err != nil {
+		return nil, i18n.T("SaveConfigFailed"), err
+	}
+	return appConfig, "", nil
+}
+
+// UpgradeCandidate 可升级的目标版本
+type UpgradeCandidate struct {
+	Version      string             `json:"version"`
+	Channel      string             `json:"channel"`
+	Allowed      bool               `json:"allowed"`                // 是否在允许升级的版本范围内（固定版本时为 false）
+	Blocked      bool               `json:"blocked"`                // 是否需要先卸载
+	BlockedBy    []RequireUninstall `json:"blocked_by"`             // 命中的“需要先卸载”要求
+	Incompatible string             `json:"incompatible,omitempty"` // 不兼容当前 DooTask 版本或平台的原因
+	Changelogs   []VersionChangelog `json:"changelogs"`             // 从已安装版本到该版本之间的更新日志（从新到旧）
+}
+
+// UpgradePlan 已安装应用的升级计划
+type UpgradePlan struct {
+	ID               string             `json:"id"`
+	InstalledVersion string             `json:"installed_version"`
+	Channel          string             `json:"channel"`
+	Pinned           bool               `json:"pinned"`
+	VersionRange     string             `json:"version_range"`
+	Candidates       []UpgradeCandidate `json:"candidates"`     // 可升级的目标版本（从新到旧）
+	NewFields        []FieldConfig      `json:"new_fields"`     // 新增的字段，升级时需要填写
+	RemovedFields    []string           `json:"removed_fields"` // 已移除的字段，升级后不再使用
+}
+
+// GetUpgradePlan 获取已安装应用的升级计划
+// - 候选版本为订阅的发布通道内高于已安装版本的版本
+// - 标记每个版本是否在允许升级的版本范围内、是否需要先卸载
+// - 对比已安装的参数，列出新增和已移除的字段
+// - 返回升级计划（第一个参数不为空表示成功）
+func GetUpgradePlan(appId string) (*UpgradePlan, string, error) {
+	app, err := NewApp(appId)
+	if err != nil {
+		return nil, i18n.T("GetAppDetailFailed"), err
+	}
+	if app.Config.Status != "installed" || app.Config.InstallVersion == "" {
+		return nil, i18n.T("AppNotInstalledError"), nil
+	}
+
+	plan := &UpgradePlan{
+		ID:               app.ID,
+		InstalledVersion: app.Config.InstallVersion,
+		Channel:          app.Config.Channel,
+		Pinned:           app.Config.Pinned,
+		VersionRange:     app.Config.VersionRange,
+		Candidates:       []UpgradeCandidate{},
+		NewFields:        []FieldConfig{},
+		RemovedFields:    []string{},
+	}
+
+	// 需要先卸载的要求与目标版本无关，只取决于已安装的版本
+	blockedBy := []RequireUninstall{}
+	for _, require := range app.RequireUninstalls {
+		if utils.CheckVersionRequirement(app.Config.InstallVersion, require.Operator, require.Version) {
+			blockedBy = append(blockedBy, require)
+		}
+	}
+
+	allowed := app.Config.AllowedVersions(app.Versions)
+	for _, version := range app.Versions {
+		if utils.CompareVersions(version, app.Config.InstallVersion) <= 0 {
+			continue
+		}
+		plan.Candidates = append(plan.Candidates, UpgradeCandidate{
+			Version:      version,
+			Channel:      VersionChannel(version),
+			Allowed:      !app.Config.Pinned && slices.Contains(allowed, version),
+			Blocked:      len(blockedBy) > 0,
+			BlockedBy:    blockedBy,
+			Incompatible: app.IncompatibleVersions[version],
+			Changelogs:   GetAggregatedChangelogs(app, app.Config.InstallVersion, version),
+		})
+	}
+
+	// 对比字段
+	fieldNames := []string{}
+	for _, field := range app.Fields {
+		fieldNames = append(fieldNames, field.Name)
+		if _, ok := app.Config.Params[field.Name]; !ok {
+			plan.NewFields = append(plan.NewFields, field)
+		}
+	}
+	for name := range app.Config.Params {
+		if !slices.Contains(fieldNames, name) {
+			plan.RemovedFields = append(plan.RemovedFields, name)
+		}
+	}
+	slices.Sort(plan.RemovedFields)
+
+	return plan, "", nil
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DigestPrefix 摘要前缀
+const DigestPrefix = "sha256:"
+
+// fileDigestCache 文件摘要缓存，按路径、大小、修改时间判断是否失效
+type fileDigestCache struct {
+	Size    int64
+	ModTime time.Time
+	Digest  string
+}
+
+// maxFileDigests 文件摘要缓存的最大数量，超出时随机淘汰
+const maxFileDigests = 20000
+
+var (
+	fileDigests     = make(map[string]fileDigestCache)
+	fileDigestMutex sync.Mutex
+)
+
+// DirDigestResult 目录摘要结果
+type DirDigestResult struct {
+	Digest    string    // 目录内容摘要（sha256:xxx）
+	Size      int64     // 文件总大小
+	UpdatedAt time.Time // 最后修改时间
+}
+
+// FileDigest 计算文件的sha256摘要（带缓存，最多缓存 maxFileDigests 个文件）
+func FileDigest(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	fileDigestMutex.Lock()
+	cache, ok := fileDigests[path]
+	fileDigestMutex.Unlock()
+	if ok && cache.Size == info.Size() && cache.ModTime.Equal(info.ModTime()) {
+		return cache.Digest, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+
+	fileDigestMutex.Lock()
+	if _, ok := fileDigests[path]; !ok && len(fileDigests) >= maxFileDigests {
+		// map 遍历顺序随机，删除任意一个
+		for key := range fileDigests {
+			delete(fileDigests, key)
+			break
+		}
+	}
+	fileDigests[path] = fileDigestCache{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Digest:  digest,
+	}
+	fileDigestMutex.Unlock()
+
+	return digest, nil
+}
+
+// DirDigest 计算目录内容摘要
+// - 只计算普通文件，按相对路径排序后依次写入“路径+文件摘要”
+// - skip 返回 true 时跳过该路径（目录则跳过整个目录），可为 nil
+// - 与文件权限、修改时间无关，相同内容在不同机器上得到相同摘要
+func DirDigest(root string, skip func(relPath string, info os.FileInfo) bool) (*DirDigestResult, error) {
+	type entry struct {
+		relPath string
+		path    string
+	}
+	result := &DirDigestResult{}
+	entries := []entry{}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		if skip != nil && skip(relPath, info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		entries = append(entries, entry{relPath: filepath.ToSlash(relPath), path: path})
+		result.Size += info.Size()
+		if info.ModTime().After(result.UpdatedAt) {
+			result.UpdatedAt = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string, len(entries))
+	for _, e := range entries {
+		digest, err := FileDigest(e.path)
+		if err != nil {
+			return nil, err
+		}
+		files[e.relPath] = digest
+	}
+	result.Digest = FilesDigest(files)
+
+	return result, nil
+}
+
+// FilesDigest 根据文件摘要计算目录内容摘要（与 DirDigest 相同），files 为相对路径（/ 分隔）到文件sha256摘要的映射
+func FilesDigest(files map[string]string) string {
+	relPaths := make([]string, 0, len(files))
+	for relPath := range files {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+
+	h := sha256.New()
+	for _, relPath := range relPaths {
+		fmt.Fprintf(h, "%s\x00%s\n", relPath, files[relPath])
+	}
+	return DigestPrefix + hex.EncodeToString(h.Sum(nil))
+}
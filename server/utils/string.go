@@ -2,6 +2,7 @@ package utils
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"net/url"
@@ -31,6 +32,13 @@ func MD5(str string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// SHA256 计算字符串的SHA256值
+func SHA256(str string) string {
+	h := sha256.New()
+	h.Write([]byte(str))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // Camel2Snake 驼峰转下划线
 func Camel2Snake(s string) string {
 	var result strings.Builder
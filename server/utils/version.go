@@ -1,43 +1,140 @@
 package utils
 
 import (
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 )
 
-// CompareVersions 比较两个版本号
-func CompareVersions(v1, v2 string) int {
-	// 移除版本号中的v前缀
-	v1 = strings.TrimPrefix(v1, "v")
-	v2 = strings.TrimPrefix(v2, "v")
+// VersionPattern 版本号格式（SemVer 2.0，兼容 v 前缀、1.2 和 1.2.3.4 这样的版本号）
+// 例如 1.2.3、v1.2、1.2.3-beta.1、1.2.3-rc.1+build.5
+const VersionPattern = `v?\d+(?:\.\d+){1,3}(?:-[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?`
+
+var versionRegex = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?$`)
 
-	// 分割版本号
-	parts1 := strings.Split(v1, ".")
-	parts2 := strings.Split(v2, ".")
+// Version 解析后的版本号
+type Version struct {
+	Numbers    []int    // 数字版本段，不足3段补0
+	Prerelease []string // 预发布标识，例如 1.2.3-beta.1 => [beta 1]
+	Build      string   // 构建元数据，不参与比较
+}
 
-	// 确保两个版本号有相同的段数
-	for len(parts1) < 3 {
-		parts1 = append(parts1, "0")
+// ParseVersion 解析版本号
+func ParseVersion(version string) (*Version, error) {
+	matches := versionRegex.FindStringSubmatch(strings.TrimSpace(version))
+	if matches == nil {
+		return nil, fmt.Errorf("invalid version: %s", version)
+	}
+	v := &Version{Build: matches[3]}
+	for _, part := range strings.Split(matches[1], ".") {
+		num, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version: %s", version)
+		}
+		v.Numbers = append(v.Numbers, num)
 	}
-	for len(parts2) < 3 {
-		parts2 = append(parts2, "0")
+	for len(v.Numbers) < 3 {
+		v.Numbers = append(v.Numbers, 0)
 	}
+	if matches[2] != "" {
+		v.Prerelease = strings.Split(matches[2], ".")
+	}
+	return v, nil
+}
 
-	// 逐段比较
-	for i := 0; i < 3; i++ {
-		num1, _ := strconv.Atoi(parts1[i])
-		num2, _ := strconv.Atoi(parts2[i])
-		if num1 < num2 {
-			return -1
+// IsValidVersion 判断是否为有效的版本目录名
+func IsValidVersion(version string) bool {
+	return regexp.MustCompile(`^` + VersionPattern + `$`).MatchString(version)
+}
+
+// Compare 比较两个版本号
+// - 数字版本段依次比较，缺少的段按0处理
+// - 有预发布标识的版本低于正式版本，例如 1.2.3-beta < 1.2.3
+// - 预发布标识逐个比较：数字按数值比较，数字低于字母，字母按ASCII比较，前面都相同时段数多的更高
+// - 构建元数据不参与比较
+func (v *Version) Compare(other *Version) int {
+	for i := 0; i < len(v.Numbers) || i < len(other.Numbers); i++ {
+		num1, num2 := 0, 0
+		if i < len(v.Numbers) {
+			num1 = v.Numbers[i]
 		}
-		if num1 > num2 {
+		if i < len(other.Numbers) {
+			num2 = other.Numbers[i]
+		}
+		if num1 != num2 {
+			return compareInt(num1, num2)
+		}
+	}
+
+	if len(v.Prerelease) == 0 || len(other.Prerelease) == 0 {
+		return compareInt(len(other.Prerelease), len(v.Prerelease))
+	}
+	for i := 0; i < len(v.Prerelease) && i < len(other.Prerelease); i++ {
+		id1, id2 := v.Prerelease[i], other.Prerelease[i]
+		if id1 == id2 {
+			continue
+		}
+		num1, err1 := strconv.Atoi(id1)
+		num2, err2 := strconv.Atoi(id2)
+		switch {
+		case err1 == nil && err2 == nil:
+			return compareInt(num1, num2)
+		case err1 == nil:
+			return -1
+		case err2 == nil:
 			return 1
+		default:
+			return strings.Compare(id1, id2)
 		}
 	}
+	return compareInt(len(v.Prerelease), len(other.Prerelease))
+}
+
+// compareInt 比较两个整数
+func compareInt(a, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
 	return 0
 }
 
+// CompareVersions 比较两个版本号（SemVer 2.0），无效的版本号低于有效的版本号
+func CompareVersions(v1, v2 string) int {
+	version1, err1 := ParseVersion(v1)
+	version2, err2 := ParseVersion(v2)
+	switch {
+	case err1 != nil && err2 != nil:
+		return strings.Compare(v1, v2)
+	case err1 != nil:
+		return -1
+	case err2 != nil:
+		return 1
+	}
+	return version1.Compare(version2)
+}
+
+// VersionSegments 解析版本号的主版本、次版本、修订号
+func VersionSegments(version string) [3]int {
+	segments := [3]int{}
+	if v, err := ParseVersion(version); err == nil {
+		copy(segments[:], v.Numbers)
+	}
+	return segments
+}
+
+// VersionPrerelease 获取版本号的预发布标识（例如 1.2.3-beta.1 => beta.1），正式版本返回空
+func VersionPrerelease(version string) string {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return ""
+	}
+	return strings.Join(v.Prerelease, ".")
+}
+
 // CheckVersionRequirement 检查版本是否满足要求
 func CheckVersionRequirement(version, operator, requiredVersion string) bool {
 	result := CompareVersions(version, requiredVersion)
@@ -74,3 +171,166 @@ func ParseVersionOperator(version string) (string, string) {
 	}
 	return "=", version
 }
+
+// versionComparator 版本约束中的单个比较条件
+type versionComparator struct {
+	operator string
+	version  string
+}
+
+// parseConstraintVersion 解析约束中的版本号，返回明确指定的版本段（遇到 x、X、* 停止）
+func parseConstraintVersion(version string) ([]int, error) {
+	segments := []int{}
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) > 4 {
+		return nil, fmt.Errorf("invalid version: %s", version)
+	}
+	for _, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		num, err := strconv.Atoi(part)
+		if err != nil || num < 0 {
+			return nil, fmt.Errorf("invalid version: %s", version)
+		}
+		segments = append(segments, num)
+	}
+	return segments, nil
+}
+
+// formatConstraintVersion 将版本段格式化为完整版本号（至少3段，支持 1.2.3.4 这样的4段版本号），index 之后的版本段补0
+// 上限版本（upper 为 true）的 index 处版本段加1，并排除上限版本的预发布版本（例如 <1.3.0-0）
+func formatConstraintVersion(segments []int, index int, upper bool) string {
+	nums := make([]string, max(3, len(segments)))
+	for i := range nums {
+		nums[i] = "0"
+		if i < len(segments) && i <= index {
+			num := segments[i]
+			if upper && i == index {
+				num++
+			}
+			nums[i] = strconv.Itoa(num)
+		}
+	}
+	if upper {
+		return strings.Join(nums, ".") + "-0"
+	}
+	return strings.Join(nums, ".")
+}
+
+// parseVersionTerm 将约束项展开为比较条件
+// - ~1.2.3 => >=1.2.3 <1.3.0，~1.2 => >=1.2.0 <1.3.0，~1 => >=1.0.0 <2.0.0
+// - ^1.2.3 => >=1.2.3 <2.0.0，^0.2.3 => >=0.2.3 <0.3.0，^0.0.3 => >=0.0.3 <0.0.4
+// - 1.2.x、1.2 => >=1.2.0 <1.3.0，1.x、1 => >=1.0.0 <2.0.0，*、x => 任意版本
+// - >=、<=、>、<、=、!= 直接比较
+// - 上限不包含上限版本的预发布版本，例如 ~1.2 不包含 1.3.0-beta
+func parseVersionTerm(term string) ([]versionComparator, error) {
+	matches := regexp.MustCompile(`^(~|\^|>=|<=|>|<|==|=|!=)?(.+)$`).FindStringSubmatch(term)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid constraint: %s", term)
+	}
+	operator, version := matches[1], matches[2]
+
+	// 带预发布标识的版本号按完整版本号比较，例如 >=1.2.3-beta.1
+	var segments []int
+	var lower string
+	wildcard := false
+	if strings.ContainsAny(version, "-+") {
+		v, err := ParseVersion(version)
+		if err != nil || len(v.Numbers) < 3 {
+			return nil, fmt.Errorf("invalid version: %s", version)
+		}
+		segments, lower = v.Numbers, version
+	} else {
+		var err error
+		if segments, err = parseConstraintVersion(version); err != nil {
+			return nil, err
+		}
+		if len(segments) == 0 {
+			if operator == "" || operator == "=" || operator == "==" {
+				return []versionComparator{}, nil
+			}
+			return nil, fmt.Errorf("invalid constraint: %s", term)
+		}
+		lower = formatConstraintVersion(segments, len(segments)-1, false)
+		wildcard = len(segments) < len(strings.Split(strings.TrimPrefix(version, "v"), "."))
+	}
+
+	switch operator {
+	case "~":
+		index := 1
+		if len(segments) == 1 {
+			index = 0
+		}
+		return []versionComparator{{">=", lower}, {"<", formatConstraintVersion(segments, index, true)}}, nil
+	case "^":
+		index := 0
+		for index < len(segments)-1 && segments[index] == 0 {
+			index++
+		}
+		return []versionComparator{{">=", lower}, {"<", formatConstraintVersion(segments, index, true)}}, nil
+	case "", "=", "==":
+		if len(segments) >= 3 && !wildcard {
+			return []versionComparator{{"=", lower}}, nil
+		}
+		return []versionComparator{{">=", lower}, {"<", formatConstraintVersion(segments, len(segments)-1, true)}}, nil
+	default:
+		return []versionComparator{{operator, lower}}, nil
+	}
+}
+
+// parseVersionConstraint 解析版本约束，|| 分隔的任一组满足即可，组内空格或逗号分隔的条件需全部满足
+func parseVersionConstraint(constraint string) ([][]versionComparator, error) {
+	// 去掉操作符与版本号之间的空格，例如 ">= 1.0"
+	constraint = regexp.MustCompile(`(~|\^|>=|<=|>|<|==|=|!=)\s+`).ReplaceAllString(constraint, "$1")
+
+	groups := [][]versionComparator{}
+	for _, group := range strings.Split(constraint, "||") {
+		comparators := []versionComparator{}
+		terms := strings.FieldsFunc(group, func(r rune) bool {
+			return r == ' ' || r == ','
+		})
+		if len(terms) == 0 {
+			return nil, fmt.Errorf("invalid constraint: %s", constraint)
+		}
+		for _, term := range terms {
+			items, err := parseVersionTerm(term)
+			if err != nil {
+				return nil, err
+			}
+			comparators = append(comparators, items...)
+		}
+		groups = append(groups, comparators)
+	}
+	return groups, nil
+}
+
+// ValidateVersionConstraint 检查版本约束格式（例如 ~1.2、^2.0.0、>=1.0 <2.0、1.x || 2.x）
+func ValidateVersionConstraint(constraint string) error {
+	_, err := parseVersionConstraint(constraint)
+	return err
+}
+
+// CheckVersionConstraint 检查版本是否满足约束，约束为空时总是满足，格式错误时不满足
+func CheckVersionConstraint(version, constraint string) bool {
+	if strings.TrimSpace(constraint) == "" {
+		return true
+	}
+	groups, err := parseVersionConstraint(constraint)
+	if err != nil {
+		return false
+	}
+	for _, comparators := range groups {
+		matched := true
+		for _, comparator := range comparators {
+			if !CheckVersionRequirement(version, comparator.operator, comparator.version) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version    string
+		numbers    []int
+		prerelease []string
+		build      string
+		wantErr    bool
+	}{
+		{version: "1.2.3", numbers: []int{1, 2, 3}},
+		{version: "v1.2", numbers: []int{1, 2, 0}},
+		{version: "1", numbers: []int{1, 0, 0}},
+		{version: "1.2.3.4", numbers: []int{1, 2, 3, 4}},
+		{version: " 1.2.3 ", numbers: []int{1, 2, 3}},
+		{version: "1.2.3-beta.1", numbers: []int{1, 2, 3}, prerelease: []string{"beta", "1"}},
+		{version: "1.2.3-rc.1+build.5", numbers: []int{1, 2, 3}, prerelease: []string{"rc", "1"}, build: "build.5"},
+		{version: "1.2.3+20240101", numbers: []int{1, 2, 3}, build: "20240101"},
+		{version: "", wantErr: true},
+		{version: "latest", wantErr: true},
+		{version: "1..2", wantErr: true},
+		{version: "1.2.3-", wantErr: true},
+		{version: "1.2.3-beta..1", wantErr: true},
+		{version: "V1.2.3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			v, err := ParseVersion(tt.version)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseVersion(%q) = %+v, want error", tt.version, v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVersion(%q) error: %v", tt.version, err)
+			}
+			if !reflect.DeepEqual(v.Numbers, tt.numbers) {
+				t.Errorf("ParseVersion(%q).Numbers = %v, want %v", tt.version, v.Numbers, tt.numbers)
+			}
+			if !reflect.DeepEqual(v.Prerelease, tt.prerelease) {
+				t.Errorf("ParseVersion(%q).Prerelease = %v, want %v", tt.version, v.Prerelease, tt.prerelease)
+			}
+			if v.Build != tt.build {
+				t.Errorf("ParseVersion(%q).Build = %q, want %q", tt.version, v.Build, tt.build)
+			}
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		v1, v2 string
+		want   int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2.3+build.1", "1.2.3+build.2", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.2.3.4", "1.2.3", 1},
+		{"1.2.3.0", "1.2.3", 0},
+		{"1.2.3-beta", "1.2.3", -1},
+		{"1.2.3", "1.2.3-rc.1", 1},
+		{"1.2.4-alpha", "1.2.3", 1},
+		// SemVer 2.0 规范中的预发布版本顺序
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{"1.0.0-beta", "1.0.0-beta.2", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-beta.11", "1.0.0-rc.1", -1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.v1+"_"+tt.v2, func(t *testing.T) {
+			v1, err := ParseVersion(tt.v1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v2, err := ParseVersion(tt.v2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := v1.Compare(v2); got != tt.want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", tt.v1, tt.v2, got, tt.want)
+			}
+			if got := v2.Compare(v1); got != -tt.want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", tt.v2, tt.v1, got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		v1, v2 string
+		want   int
+	}{
+		{"1.0.0", "1.0.0-beta", 1},
+		{"invalid", "1.0.0", -1},
+		{"1.0.0", "invalid", 1},
+		{"a", "b", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.v1, tt.v2); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.v1, tt.v2, got, tt.want)
+		}
+	}
+}
+
+func TestCheckVersionConstraint(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		want       bool
+	}{
+		// 空约束和通配符
+		{"1.2.3", "", true},
+		{"1.2.3", "  ", true},
+		{"1.2.3", "*", true},
+		{"1.2.3", "x", true},
+
+		// 波浪号
+		{"1.2.0", "~1.2", true},
+		{"1.2.9", "~1.2", true},
+		{"1.3.0", "~1.2", false},
+		{"1.3.0-beta", "~1.2", false},
+		{"1.1.9", "~1.2", false},
+		{"1.2.3", "~1.2.3", true},
+		{"1.2.2", "~1.2.3", false},
+		{"1.9.0", "~1", true},
+		{"2.0.0", "~1", false},
+
+		// 插入号
+		{"1.9.9", "^1.2.3", true},
+		{"2.0.0", "^1.2.3", false},
+		{"1.2.2", "^1.2.3", false},
+		{"0.2.9", "^0.2.3", true},
+		{"0.3.0", "^0.2.3", false},
+		{"0.0.3", "^0.0.3", true},
+		{"0.0.4", "^0.0.3", false},
+
+		// 部分版本号和 x 通配
+		{"1.2.7", "1.2.x", true},
+		{"1.3.0", "1.2.x", false},
+		{"1.2.7", "1.2", true},
+		{"1.9.0", "1.x", true},
+		{"2.0.0", "1.x", false},
+		{"1.0.0", "1", true},
+
+		// 精确版本
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "=1.2.3", true},
+		{"1.2.4", "1.2.3", false},
+		{"v1.2.3", "1.2.3", true},
+
+		// 比较操作符
+		{"1.0.0", ">=1.0", true},
+		{"0.9.9", ">=1.0", false},
+		{"1.5.0", ">=1.0 <2.0", true},
+		{"2.0.0", ">=1.0 <2.0", false},
+		{"1.5.0", ">= 1.0, < 2.0", true},
+		{"1.0.1", ">1.0.0", true},
+		{"1.0.0", ">1.0.0", false},
+		{"1.0.0", "<=1.0.0", true},
+		{"1.0.0", "!=1.0.0", false},
+		{"1.0.1", "!=1.0.0", true},
+
+		// 预发布版本
+		{"1.2.3-beta.2", ">=1.2.3-beta.1", true},
+		{"1.2.3-alpha", ">=1.2.3-beta.1", false},
+		{"1.2.3", ">=1.2.3-beta.1", true},
+
+		// 或
+		{"1.5.0", "1.x || 2.x", true},
+		{"2.5.0", "1.x || 2.x", true},
+		{"3.0.0", "1.x || 2.x", false},
+		{"0.5.0", "<1.0 || >=3.0", true},
+
+		// 格式错误时不满足
+		{"1.2.3", "~", false},
+		{"1.2.3", ">=abc", false},
+		{"1.2.3", "1.x ||", false},
+		{"1.2.3", "1.2.3.4.5", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version+" "+tt.constraint, func(t *testing.T) {
+			if got := CheckVersionConstraint(tt.version, tt.constraint); got != tt.want {
+				t.Errorf("CheckVersionConstraint(%q, %q) = %v, want %v", tt.version, tt.constraint, got, tt.want)
+			}
+		})
+	}
+}